@@ -1,6 +1,8 @@
 package expr
 
-import "regexp"
+import (
+	"context"
+)
 
 // Expr expr
 type Expr interface {
@@ -10,6 +12,12 @@ type Expr interface {
 // VarExprFactory factory method
 type VarExprFactory func([]byte, VarType) (Expr, error)
 
+// Span the [Start, End) byte offsets of a expr in the input, see PositionOf
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
 type stack struct {
 	nodes []*node
 }
@@ -23,8 +31,8 @@ func (s *stack) append(v *node) {
 	s.push(v)
 }
 
-func (s *stack) appendWithOP(fn CalcFunc, v *node) {
-	s.current().appendWithOP(fn, v)
+func (s *stack) appendWithOP(op *operator, span Span, v *node) {
+	s.current().appendWithOP(op, span, v)
 	s.push(v)
 }
 
@@ -40,9 +48,58 @@ func (s *stack) pop() Expr {
 	return v
 }
 
+// source the source text of a expr
+type source struct {
+	text string
+}
+
+func (s *source) sourceText() string {
+	return s.text
+}
+
+func (s *source) setSourceText(text string) {
+	s.text = text
+}
+
+// constTrace the trace of the const operand, it's only set on the copy of the const
+// passed to the op by Explain
+type constTrace struct {
+	trace *Trace
+}
+
+// executed marks the const operand is executed by the op
+func (c *constTrace) executed() {
+	if c.trace != nil {
+		c.trace.ShortCircuited = false
+	}
+}
+
+type sourceExpr interface {
+	sourceText() string
+	setSourceText(string)
+}
+
+type operator struct {
+	symbol     string
+	fn         CalcFunc
+	ctxFn      ContextCalcFunc
+	fallback   CalcFunc
+	signatures []Signature
+	overloads  []overload
+	attrs      OpAttr
+}
+
 type node struct {
-	exprs []Expr
-	fns   []CalcFunc
+	source
+	span    Span
+	exprs   []Expr
+	ops     []*operator
+	opSpans []Span
+	fns     []CalcFunc
+	// budget the budget of the root node
+	budget *Budget
+	// comments the comments of the root node
+	comments []Comment
 }
 
 func (n *node) add(expr Expr) {
@@ -53,12 +110,18 @@ func (n *node) append(expr Expr) {
 	n.exprs = append(n.exprs, expr)
 }
 
-func (n *node) appendWithOP(fn CalcFunc, expr Expr) {
+func (n *node) appendWithOP(op *operator, span Span, expr Expr) {
 	n.exprs = append(n.exprs, expr)
-	n.fns = append(n.fns, fn)
+	n.ops = append(n.ops, op)
+	n.opSpans = append(n.opSpans, span)
+	n.fns = append(n.fns, op.fn)
 }
 
 func (n *node) Exec(ctx interface{}) (interface{}, error) {
+	if n.budget != nil {
+		return n.ExecContext(context.Background(), ctx)
+	}
+
 	left, err := n.exprs[0].Exec(ctx)
 	if err != nil {
 		return nil, err
@@ -74,34 +137,182 @@ func (n *node) Exec(ctx interface{}) (interface{}, error) {
 	return left, nil
 }
 
+type varExpr struct {
+	source
+	name    string
+	varType VarType
+	span    Span
+	expr    Expr
+}
+
+func (expr *varExpr) Exec(ctx interface{}) (interface{}, error) {
+	return expr.expr.Exec(ctx)
+}
+
 type constString struct {
+	source
+	constTrace
 	value string
 }
 
 func (expr *constString) Exec(ctx interface{}) (interface{}, error) {
+	expr.executed()
 	return expr.value, nil
 }
 
 type constInt64 struct {
+	source
+	constTrace
 	value int64
 }
 
 func (expr *constInt64) Exec(ctx interface{}) (interface{}, error) {
+	expr.executed()
 	return expr.value, nil
 }
 
 type constRegexp struct {
-	value *regexp.Regexp
+	source
+	constTrace
+	pattern string
+	flags   string
+	value   Matcher
+}
+
+func newRegexpExpr(pattern, flags string, factory MatcherFactory) (*constRegexp, error) {
+	value, err := factory(pattern, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return &constRegexp{
+		pattern: pattern,
+		flags:   flags,
+		value:   value,
+	}, nil
 }
 
 func (expr *constRegexp) Exec(ctx interface{}) (interface{}, error) {
+	expr.executed()
+	return expr.value, nil
+}
+
+type constGlob struct {
+	source
+	constTrace
+	value *GlobPattern
+}
+
+func (expr *constGlob) Exec(ctx interface{}) (interface{}, error) {
+	expr.executed()
+	return expr.value, nil
+}
+
+type constValue struct {
+	source
+	constTrace
+	value interface{}
+}
+
+func (expr *constValue) Exec(ctx interface{}) (interface{}, error) {
+	expr.executed()
 	return expr.value, nil
 }
 
 type constArray struct {
+	source
+	constTrace
 	values []string
+	set    *Set
+}
+
+func newArrayExpr(values []string, asSet bool) *constArray {
+	expr := &constArray{values: values}
+	if asSet {
+		expr.set = NewSet(values...)
+	}
+
+	return expr
 }
 
 func (expr *constArray) Exec(ctx interface{}) (interface{}, error) {
+	expr.executed()
+	if expr.set != nil {
+		return expr.set, nil
+	}
+
 	return expr.values, nil
 }
+
+func newValueExpr(value interface{}) Expr {
+	if v, ok := int64Value(value); ok {
+		return &constInt64{value: v}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return &constString{value: v}
+	case *GlobPattern:
+		return &constGlob{value: v}
+	case Matcher:
+		return &constRegexp{pattern: v.String(), value: v}
+	case []string:
+		return &constArray{values: v}
+	case *Set:
+		return &constArray{values: v.Values(), set: v}
+	default:
+		return &constValue{value: v}
+	}
+}
+
+// int64Value returns the int64 of the value of all the integer kinds
+func int64Value(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case uint:
+		return int64(v), true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// Comments returns the comments in the input of the expr returned by Parser with WithComments
+func Comments(expr Expr) []Comment {
+	if n, ok := expr.(*node); ok {
+		return n.comments
+	}
+
+	return nil
+}
+
+// walk calls fn for the expr and all the sub exprs in depth-first order
+func walk(expr Expr, fn func(Expr)) {
+	fn(expr)
+
+	if n, ok := expr.(*node); ok {
+		for _, sub := range n.exprs {
+			walk(sub, fn)
+		}
+	} else if c, ok := expr.(*castExpr); ok {
+		walk(c.expr, fn)
+	} else if m, ok := expr.(*macroExpr); ok {
+		walk(m.expr, fn)
+	}
+}
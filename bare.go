@@ -0,0 +1,69 @@
+package expr
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bareVar returns the name and the var type of the bare identifier `name` or `name:type`,
+// the type is the name of the VarType or the var type symbol without the `:`, e.g. `x:num`.
+// The consts are not bare vars, it returns the error if the type is not a known var type.
+func (p *parserTemplate) bareVar(value []byte) (string, VarType, bool, error) {
+	if _, ok := p.opts.consts[string(value)]; ok {
+		return "", Any, false, nil
+	}
+
+	name, typ := value, []byte(nil)
+	if idx := bytes.LastIndexByte(value, ':'); idx >= 0 {
+		name, typ = value[:idx], value[idx+1:]
+	}
+
+	if !isIdentifier(name) || (typ != nil && !isIdentifier(typ)) {
+		return "", Any, false, nil
+	}
+
+	if _, ok := boolLiterals[string(name)]; ok {
+		return "", Any, false, nil
+	}
+
+	if typ == nil {
+		return string(name), p.opts.defaultType, true, nil
+	}
+
+	if varType, ok := p.opts.typs[string(typ)+":"]; ok {
+		return string(name), varType, true, nil
+	}
+
+	for varType, n := range varTypeNames {
+		if n == string(typ) && varType != Any {
+			return string(name), varType, true, nil
+		}
+	}
+
+	return "", Any, false, fmt.Errorf("var type %s of %s not support", typ, value)
+}
+
+// isIdentifier returns true if the value starts with a letter or `_`, and follows
+// by the letters, digits, `_` and `.`, e.g. `user.name`
+func isIdentifier(value []byte) bool {
+	if len(value) == 0 {
+		return false
+	}
+
+	for i, w := 0, 0; i < len(value); i += w {
+		var r rune
+		r, w = utf8.DecodeRune(value[i:])
+		if r == '_' || unicode.IsLetter(r) {
+			continue
+		}
+
+		if i == 0 || (r != '.' && !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+
+	return !strings.HasSuffix(string(value), ".")
+}
@@ -0,0 +1,84 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsIdentifier(t *testing.T) {
+	for _, value := range []string{"a", "_a", "user.name", "a1", "名字"} {
+		assert.True(t, isIdentifier([]byte(value)), "TestIsIdentifier failed")
+	}
+
+	for _, value := range []string{"", "1a", ".a", "a.", "a-b", "a b", `"a"`} {
+		assert.False(t, isIdentifier([]byte(value)), "TestIsIdentifier failed")
+	}
+}
+
+func TestParserWithBareVars(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("+", testAdd),
+		WithOp("==", testEqual),
+		WithOp("===", testStrEqual),
+		WithOp("&&", testAndLogic),
+		WithVarType("s:", Str),
+		WithDefaultVarType(Num),
+		WithConst("ten", int64(10)),
+		WithBareVars())
+
+	ctx := make(map[string]string)
+	ctx["user.age"] = "9"
+	ctx["user.name"] = "abc"
+
+	var vars []Expr
+	input := "(user.age + 1 == ten) && (user.name:s === abc:str) && (user.age:num == 9)"
+	expr, err := p.Parse([]byte(input), func(e Expr) {
+		vars = append(vars, e)
+	})
+	assert.NoError(t, err, "TestParserWithBareVars failed")
+	assert.Equal(t, 4, len(vars), "TestParserWithBareVars failed")
+
+	ctx["abc"] = "abc"
+	value, err := expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithBareVars failed")
+	assert.Equal(t, true, value, "TestParserWithBareVars failed")
+
+	refs := Variables(expr)
+	assert.Equal(t, VarRef{Name: "user.age", Type: Num, Span: Span{Start: 1, End: 9}}, refs[0], "TestParserWithBareVars failed")
+	assert.Equal(t, "user.name:s", input[refs[1].Span.Start:refs[1].Span.End], "TestParserWithBareVars failed")
+	assert.Equal(t, Str, refs[1].Type, "TestParserWithBareVars failed")
+	assert.Equal(t, Str, refs[2].Type, "TestParserWithBareVars failed")
+
+	_, err = p.Parse([]byte("x:unknown === x:unknown"), nil)
+	assert.Error(t, err, "TestParserWithBareVars failed")
+	assert.Contains(t, err.Error(), "var type unknown of x:unknown not support", "TestParserWithBareVars failed")
+
+	_, err = p.Parse([]byte("age:nmu == 1"), nil)
+	assert.Error(t, err, "TestParserWithBareVars failed")
+
+	// the suffix is not a type name
+	_, err = p.Parse([]byte("{str:url} === http://a"), nil)
+	assert.NoError(t, err, "TestParserWithBareVars failed")
+
+	// the spans skip the comments
+	input = "a /* note */ == 1 && /* c */ b == (\tc /* d */)"
+	cp := NewParser(testVarFactory,
+		WithOp("==", testEqual),
+		WithOp("&&", testAndLogic),
+		WithDefaultVarType(Num),
+		WithBareVars(),
+		WithComments())
+	expr, err = cp.Parse([]byte(input), nil)
+	assert.NoError(t, err, "TestParserWithBareVars failed")
+	refs = Variables(expr)
+	assert.Equal(t, 3, len(refs), "TestParserWithBareVars failed")
+	for _, ref := range refs {
+		assert.Equal(t, ref.Name, input[ref.Span.Start:ref.Span.End], "TestParserWithBareVars failed")
+	}
+
+	tokens, err := Tokenize(p, []byte("user.age + ten"))
+	assert.NoError(t, err, "TestParserWithBareVars failed")
+	assert.Equal(t, KindVarName, tokens[0].Kind, "TestParserWithBareVars failed")
+	assert.Equal(t, KindText, tokens[2].Kind, "TestParserWithBareVars failed")
+}
@@ -0,0 +1,423 @@
+package expr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	binaryVersion byte = 1
+	// binaryKindMask the kind in the low bits of the kind byte, the high bits of the
+	// node kind byte are the number of the sub exprs if less than 16
+	binaryKindMask   byte = 0x0f
+	binaryCountShift      = 4
+)
+
+const (
+	binaryNode byte = iota
+	binaryVar
+	binaryStr
+	binaryNum
+	binaryRegexp
+	binaryArray
+	binaryBool
+	binaryRegexpFlags
+	binaryGlob
+	binaryCast
+	binaryMacro
+)
+
+var (
+	binaryMagic = []byte("EX")
+
+	// ErrInvalidEncoding the data is not returned by EncodeExpr
+	ErrInvalidEncoding = errors.New("invalid expr encoding")
+
+	binaryKinds = map[string]byte{
+		kindNode:   binaryNode,
+		kindVar:    binaryVar,
+		kindStr:    binaryStr,
+		kindNum:    binaryNum,
+		kindRegexp: binaryRegexp,
+		kindArray:  binaryArray,
+		kindBool:   binaryBool,
+		kindGlob:   binaryGlob,
+		kindCast:   binaryCast,
+		kindMacro:  binaryMacro,
+	}
+)
+
+// EncodeExpr returns the compact binary encoding of the expr returned by Parser.
+// The encoding starts with the magic and the version, then the op symbols table
+// and the exprs in pre-order. The source texts, the op spans and the groups with
+// only one expr like the var braces are not encoded.
+func EncodeExpr(expr Expr) ([]byte, error) {
+	desc, err := describe(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &encoder{symbols: make(map[string]uint64)}
+	e.collect(desc)
+
+	var buf bytes.Buffer
+	buf.Write(binaryMagic)
+	buf.WriteByte(binaryVersion)
+	e.writeUvarint(&buf, uint64(len(e.table)))
+	for _, symbol := range e.table {
+		e.writeString(&buf, symbol)
+	}
+	e.write(&buf, desc)
+	return buf.Bytes(), nil
+}
+
+// DecodeExpr returns the executable expr from the data returned by EncodeExpr, the ops
+// and the vars are created by the registry and the factory of the parser, it fails
+// if the op is not registered.
+func DecodeExpr(parser Parser, data []byte) (Expr, error) {
+	p, err := templateOf(parser)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < len(binaryMagic)+1 || !bytes.Equal(data[:len(binaryMagic)], binaryMagic) {
+		return nil, ErrInvalidEncoding
+	}
+
+	if version := data[len(binaryMagic)]; version != binaryVersion {
+		return nil, fmt.Errorf("%w: version %d not support", ErrInvalidEncoding, version)
+	}
+
+	d := &decoder{r: bytes.NewReader(data[len(binaryMagic)+1:])}
+	n, err := d.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := uint64(0); i < n; i++ {
+		symbol, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := p.opsBySymbol[symbol]; !ok {
+			return nil, fmt.Errorf("op <%s> not support", symbol)
+		}
+		d.table = append(d.table, symbol)
+	}
+
+	desc, err := d.read()
+	if err != nil {
+		return nil, err
+	}
+
+	if d.r.Len() > 0 {
+		return nil, fmt.Errorf("%w: %d bytes left", ErrInvalidEncoding, d.r.Len())
+	}
+
+	expr, err := p.build(desc)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.finish(expr)
+}
+
+type encoder struct {
+	symbols map[string]uint64
+	table   []string
+	tmp     [binary.MaxVarintLen64]byte
+}
+
+func (e *encoder) collect(desc *exprDesc) {
+	for _, op := range desc.Ops {
+		if _, ok := e.symbols[op]; !ok {
+			e.symbols[op] = uint64(len(e.table))
+			e.table = append(e.table, op)
+		}
+	}
+
+	for _, sub := range desc.Exprs {
+		e.collect(sub)
+	}
+}
+
+func (e *encoder) write(buf *bytes.Buffer, desc *exprDesc) {
+	if desc.Kind == kindRegexp && desc.Flags != "" {
+		buf.WriteByte(binaryRegexpFlags)
+		e.writeString(buf, desc.Value.(string))
+		e.writeString(buf, desc.Flags)
+		return
+	}
+
+	if desc.Kind == kindNode && len(desc.Exprs) < 1<<binaryCountShift {
+		buf.WriteByte(binaryNode | byte(len(desc.Exprs))<<binaryCountShift)
+	} else {
+		buf.WriteByte(binaryKinds[desc.Kind])
+	}
+
+	switch desc.Kind {
+	case kindNode:
+		if len(desc.Exprs) >= 1<<binaryCountShift {
+			e.writeUvarint(buf, uint64(len(desc.Exprs)))
+		}
+		for _, op := range desc.Ops {
+			e.writeUvarint(buf, e.symbols[op])
+		}
+		for _, sub := range desc.Exprs {
+			e.write(buf, ungroup(sub))
+		}
+	case kindCast:
+		varType, _ := ParseVarType(desc.Type)
+		e.writeVarint(buf, int64(varType))
+		e.write(buf, ungroup(desc.Exprs[0]))
+	case kindMacro:
+		e.writeString(buf, desc.Name)
+		e.writeUvarint(buf, uint64(desc.Span.Start))
+		e.writeUvarint(buf, uint64(desc.Span.End))
+		e.write(buf, ungroup(desc.Exprs[0]))
+	case kindVar:
+		varType, _ := ParseVarType(desc.Type)
+		e.writeString(buf, desc.Name)
+		e.writeVarint(buf, int64(varType))
+		e.writeUvarint(buf, uint64(desc.Span.Start))
+		e.writeUvarint(buf, uint64(desc.Span.End))
+	case kindStr, kindRegexp, kindGlob:
+		e.writeString(buf, desc.Value.(string))
+	case kindNum:
+		e.writeVarint(buf, desc.Value.(int64))
+	case kindArray:
+		values := desc.Value.([]string)
+		e.writeUvarint(buf, uint64(len(values)))
+		for _, v := range values {
+			e.writeString(buf, v)
+		}
+	case kindBool:
+		if desc.Value.(bool) {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	}
+}
+
+// ungroup returns the only expr of the group
+func ungroup(desc *exprDesc) *exprDesc {
+	for desc.Kind == kindNode && len(desc.Exprs) == 1 {
+		desc = desc.Exprs[0]
+	}
+
+	return desc
+}
+
+func (e *encoder) writeUvarint(buf *bytes.Buffer, v uint64) {
+	n := binary.PutUvarint(e.tmp[:], v)
+	buf.Write(e.tmp[:n])
+}
+
+func (e *encoder) writeVarint(buf *bytes.Buffer, v int64) {
+	n := binary.PutVarint(e.tmp[:], v)
+	buf.Write(e.tmp[:n])
+}
+
+func (e *encoder) writeString(buf *bytes.Buffer, v string) {
+	e.writeUvarint(buf, uint64(len(v)))
+	buf.WriteString(v)
+}
+
+type decoder struct {
+	r     *bytes.Reader
+	table []string
+}
+
+func (d *decoder) read() (*exprDesc, error) {
+	kind, err := d.r.ReadByte()
+	if err != nil {
+		return nil, d.wrap(err)
+	}
+
+	count := uint64(kind >> binaryCountShift)
+	kind &= binaryKindMask
+	if count > 0 && kind != binaryNode {
+		return nil, fmt.Errorf("%w: kind %d with count", ErrInvalidEncoding, kind)
+	}
+
+	desc := &exprDesc{}
+	switch kind {
+	case binaryNode:
+		desc.Kind = kindNode
+		n := count
+		if n == 0 {
+			if n, err = d.readUvarint(); err != nil {
+				return nil, err
+			}
+		}
+		if n == 0 || n > uint64(d.r.Len()) {
+			return nil, fmt.Errorf("%w: node with %d exprs", ErrInvalidEncoding, n)
+		}
+
+		for i := uint64(1); i < n; i++ {
+			idx, err := d.readUvarint()
+			if err != nil {
+				return nil, err
+			}
+			if idx >= uint64(len(d.table)) {
+				return nil, fmt.Errorf("%w: op %d not in table", ErrInvalidEncoding, idx)
+			}
+			desc.Ops = append(desc.Ops, d.table[idx])
+		}
+
+		for i := uint64(0); i < n; i++ {
+			sub, err := d.read()
+			if err != nil {
+				return nil, err
+			}
+			desc.Exprs = append(desc.Exprs, sub)
+		}
+	case binaryCast:
+		desc.Kind = kindCast
+		varType, err := d.readVarint()
+		if err != nil {
+			return nil, err
+		}
+		desc.Type = VarType(varType).String()
+
+		sub, err := d.read()
+		if err != nil {
+			return nil, err
+		}
+		desc.Exprs = append(desc.Exprs, sub)
+	case binaryMacro:
+		desc.Kind = kindMacro
+		if desc.Name, err = d.readString(); err != nil {
+			return nil, err
+		}
+		if desc.Span, err = d.readSpan(); err != nil {
+			return nil, err
+		}
+
+		sub, err := d.read()
+		if err != nil {
+			return nil, err
+		}
+		desc.Exprs = append(desc.Exprs, sub)
+	case binaryVar:
+		desc.Kind = kindVar
+		if desc.Name, err = d.readString(); err != nil {
+			return nil, err
+		}
+		varType, err := d.readVarint()
+		if err != nil {
+			return nil, err
+		}
+		desc.Type = VarType(varType).String()
+		if desc.Span, err = d.readSpan(); err != nil {
+			return nil, err
+		}
+	case binaryStr, binaryRegexp, binaryGlob:
+		desc.Kind = kindStr
+		if kind == binaryRegexp {
+			desc.Kind = kindRegexp
+		} else if kind == binaryGlob {
+			desc.Kind = kindGlob
+		}
+		if desc.Value, err = d.readString(); err != nil {
+			return nil, err
+		}
+	case binaryRegexpFlags:
+		desc.Kind = kindRegexp
+		if desc.Value, err = d.readString(); err != nil {
+			return nil, err
+		}
+		if desc.Flags, err = d.readString(); err != nil {
+			return nil, err
+		}
+	case binaryNum:
+		desc.Kind = kindNum
+		if desc.Value, err = d.readVarint(); err != nil {
+			return nil, err
+		}
+	case binaryArray:
+		desc.Kind = kindArray
+		n, err := d.readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		if n > uint64(d.r.Len()) {
+			return nil, fmt.Errorf("%w: array with %d values", ErrInvalidEncoding, n)
+		}
+
+		values := make([]string, 0, n)
+		for i := uint64(0); i < n; i++ {
+			v, err := d.readString()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		desc.Value = values
+	case binaryBool:
+		desc.Kind = kindBool
+		v, err := d.r.ReadByte()
+		if err != nil {
+			return nil, d.wrap(err)
+		}
+		desc.Value = v == 1
+	default:
+		return nil, fmt.Errorf("%w: kind %d not support", ErrInvalidEncoding, kind)
+	}
+
+	return desc, nil
+}
+
+func (d *decoder) readUvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(d.r)
+	return v, d.wrap(err)
+}
+
+func (d *decoder) readVarint() (int64, error) {
+	v, err := binary.ReadVarint(d.r)
+	return v, d.wrap(err)
+}
+
+func (d *decoder) readSpan() (*Span, error) {
+	start, err := d.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := d.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Span{Start: int(start), End: int(end)}, nil
+}
+
+func (d *decoder) readString() (string, error) {
+	n, err := d.readUvarint()
+	if err != nil {
+		return "", err
+	}
+
+	if n > uint64(d.r.Len()) {
+		return "", fmt.Errorf("%w: string length %d", ErrInvalidEncoding, n)
+	}
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		return "", d.wrap(err)
+	}
+	return string(buf), nil
+}
+
+func (d *decoder) wrap(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: unexpected end", ErrInvalidEncoding)
+	}
+
+	return err
+}
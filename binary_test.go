@@ -0,0 +1,56 @@
+package expr
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeExpr(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("+", testAdd),
+		WithOp("==", testEqual),
+		WithOp("&&", testAndLogic),
+		WithOp("in", testStrIn),
+		WithOp("~", testMatch),
+		WithVarType("num:", Num),
+		WithVarType("str:", Str),
+		WithConst("ENABLED", true))
+
+	ctx := make(map[string]string)
+	ctx["a"] = "1"
+	ctx["b"] = "abc"
+
+	input := "(({num:a}+-1==0)&&({str:b} in [abc,d]))&&({str:b}~|^a.c$|)&&{ENABLED}"
+	expr, err := p.Parse([]byte(input), nil)
+	assert.NoError(t, err, "TestEncodeExpr failed")
+
+	data, err := EncodeExpr(expr)
+	assert.NoError(t, err, "TestEncodeExpr failed")
+	assert.True(t, len(data) < len(input), "TestEncodeExpr failed")
+
+	value, err := DecodeExpr(p, data)
+	assert.NoError(t, err, "TestEncodeExpr failed")
+	result, err := value.Exec(ctx)
+	assert.NoError(t, err, "TestEncodeExpr failed")
+	assert.Equal(t, true, result, "TestEncodeExpr failed")
+	assert.Equal(t, Variables(expr), Variables(value), "TestEncodeExpr failed")
+
+	again, err := EncodeExpr(value)
+	assert.NoError(t, err, "TestEncodeExpr failed")
+	assert.Equal(t, data, again, "TestEncodeExpr failed")
+
+	_, err = DecodeExpr(NewParser(testVarFactory, WithOp("+", testAdd)), data)
+	assert.Error(t, err, "TestEncodeExpr failed")
+
+	for i := 0; i < len(data); i++ {
+		_, err = DecodeExpr(p, data[:i])
+		assert.Error(t, err, "TestEncodeExpr failed")
+	}
+
+	invalid := append([]byte(nil), data...)
+	invalid[2] = binaryVersion + 1
+	_, err = DecodeExpr(p, invalid)
+	assert.True(t, errors.Is(err, ErrInvalidEncoding), "TestEncodeExpr failed")
+}
@@ -0,0 +1,113 @@
+package expr
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// BudgetOps the number of op evaluations
+	BudgetOps = "ops"
+	// BudgetDepth the recursion depth
+	BudgetDepth = "depth"
+	// BudgetArraySize the size of array values
+	BudgetArraySize = "array size"
+	// BudgetRegexpLength the pattern length of regexp values
+	BudgetRegexpLength = "regexp length"
+)
+
+// Budget limits the cost of executing a expr, zero means no limit
+type Budget struct {
+	// MaxOps max number of op evaluations
+	MaxOps int
+	// MaxDepth max recursion depth of the sub exprs
+	MaxDepth int
+	// MaxArraySize max size of the array literals and var values
+	MaxArraySize int
+	// MaxRegexpLength max pattern length of the regexp literals and var values
+	MaxRegexpLength int
+}
+
+// BudgetError the budget exceeded
+type BudgetError struct {
+	Kind  string
+	Limit int
+}
+
+func (err *BudgetError) Error() string {
+	return fmt.Sprintf("exceed the budget of %s %d", err.Kind, err.Limit)
+}
+
+func (b *Budget) checkValue(value interface{}) error {
+	switch v := value.(type) {
+	case []string:
+		if b.MaxArraySize > 0 && len(v) > b.MaxArraySize {
+			return &BudgetError{Kind: BudgetArraySize, Limit: b.MaxArraySize}
+		}
+	case *Set:
+		if b.MaxArraySize > 0 && len(v.Values()) > b.MaxArraySize {
+			return &BudgetError{Kind: BudgetArraySize, Limit: b.MaxArraySize}
+		}
+	case *GlobPattern:
+		// the globs are matched without the regexps
+	case Matcher:
+		if b.MaxRegexpLength > 0 && len(v.String()) > b.MaxRegexpLength {
+			return &BudgetError{Kind: BudgetRegexpLength, Limit: b.MaxRegexpLength}
+		}
+	}
+
+	return nil
+}
+
+type trackerKey struct{}
+
+// tracker tracks the cost of a evaluation
+type tracker struct {
+	budget *Budget
+	ops    int
+	depth  int
+}
+
+func withTracker(ctx context.Context, budget *Budget) context.Context {
+	if budget == nil || trackerFrom(ctx) != nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, trackerKey{}, &tracker{budget: budget})
+}
+
+func trackerFrom(ctx context.Context) *tracker {
+	if ctx == nil {
+		return nil
+	}
+
+	t, _ := ctx.Value(trackerKey{}).(*tracker)
+	return t
+}
+
+// addOp adds a op evaluation, nil tracker means no budget
+func (t *tracker) addOp() error {
+	if t == nil {
+		return nil
+	}
+
+	t.ops++
+	if t.budget.MaxOps > 0 && t.ops > t.budget.MaxOps {
+		return &BudgetError{Kind: BudgetOps, Limit: t.budget.MaxOps}
+	}
+
+	return nil
+}
+
+func (t *tracker) enter(depth int) error {
+	t.depth += depth
+	if t.budget.MaxDepth > 0 && t.depth > t.budget.MaxDepth {
+		return &BudgetError{Kind: BudgetDepth, Limit: t.budget.MaxDepth}
+	}
+
+	return nil
+}
+
+func (t *tracker) leave(depth int) {
+	t.depth -= depth
+}
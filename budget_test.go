@@ -0,0 +1,98 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudgetOps(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("+", testAdd),
+		WithOp("==", testEqual),
+		WithBudget(Budget{MaxOps: 3}))
+
+	expr, err := p.Parse([]byte("1+2+3==6"), nil)
+	assert.NoError(t, err, "TestBudgetOps failed")
+	value, err := expr.Exec(nil)
+	assert.NoError(t, err, "TestBudgetOps failed")
+	assert.Equal(t, true, value, "TestBudgetOps failed")
+
+	expr, err = p.Parse([]byte("1+2+3+4==10"), nil)
+	assert.NoError(t, err, "TestBudgetOps failed")
+	_, err = expr.Exec(nil)
+	assert.Error(t, err, "TestBudgetOps failed")
+	assert.Equal(t, BudgetOps, err.(*BudgetError).Kind, "TestBudgetOps failed")
+
+	prog, err := Compile(expr)
+	assert.NoError(t, err, "TestBudgetOps failed")
+	_, err = prog.Exec(nil)
+	assert.Error(t, err, "TestBudgetOps failed")
+	assert.Equal(t, BudgetOps, err.(*BudgetError).Kind, "TestBudgetOps failed")
+}
+
+func TestBudgetDepth(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("+", testAdd),
+		WithBudget(Budget{MaxDepth: 3}))
+
+	expr, err := p.Parse([]byte("1+(2+3)"), nil)
+	assert.NoError(t, err, "TestBudgetDepth failed")
+	_, err = expr.Exec(nil)
+	assert.NoError(t, err, "TestBudgetDepth failed")
+
+	expr, err = p.Parse([]byte("1+(2+(3+(4+5)))"), nil)
+	assert.NoError(t, err, "TestBudgetDepth failed")
+	_, err = expr.Exec(nil)
+	assert.Error(t, err, "TestBudgetDepth failed")
+	assert.Equal(t, BudgetDepth, err.(*BudgetError).Kind, "TestBudgetDepth failed")
+}
+
+func TestBudgetValues(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("in", testStrIn),
+		WithOp("~", testMatch),
+		WithVarType("str:", Str),
+		WithVarType("regexp:", Regexp),
+		WithBudget(Budget{MaxArraySize: 2, MaxRegexpLength: 3}))
+
+	_, err := p.Parse([]byte("{str:1} in [1,2,3]"), nil)
+	assert.Error(t, err, "TestBudgetValues failed")
+	assert.Equal(t, BudgetArraySize, err.(*BudgetError).Kind, "TestBudgetValues failed")
+
+	_, err = p.Parse([]byte("{str:1}~|abcd|"), nil)
+	assert.Error(t, err, "TestBudgetValues failed")
+	assert.Equal(t, BudgetRegexpLength, err.(*BudgetError).Kind, "TestBudgetValues failed")
+
+	ctx := make(map[string]string)
+	ctx["1"] = "abc"
+	ctx["2"] = "abcd"
+
+	expr, err := p.Parse([]byte("{str:1}~{regexp:2}"), nil)
+	assert.NoError(t, err, "TestBudgetValues failed")
+	_, err = expr.Exec(ctx)
+	assert.Error(t, err, "TestBudgetValues failed")
+	assert.Equal(t, BudgetRegexpLength, err.(*BudgetError).Kind, "TestBudgetValues failed")
+
+	// the consts of the decoded exprs are checked
+	unlimited := NewParser(testVarFactory,
+		WithOp("in", testStrIn),
+		WithOp("~", testMatch),
+		WithVarType("str:", Str))
+	for _, input := range []string{"{str:1} in [1,2,3]", "{str:1}~|abcd|"} {
+		expr, err = unlimited.Parse([]byte(input), nil)
+		assert.NoError(t, err, "TestBudgetValues failed")
+
+		data, err := MarshalExpr(expr)
+		assert.NoError(t, err, "TestBudgetValues failed")
+		_, err = UnmarshalExpr(p, data)
+		assert.Error(t, err, "TestBudgetValues failed")
+		assert.IsType(t, &BudgetError{}, err, "TestBudgetValues failed")
+
+		data, err = EncodeExpr(expr)
+		assert.NoError(t, err, "TestBudgetValues failed")
+		_, err = DecodeExpr(p, data)
+		assert.Error(t, err, "TestBudgetValues failed")
+		assert.IsType(t, &BudgetError{}, err, "TestBudgetValues failed")
+	}
+}
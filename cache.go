@@ -0,0 +1,83 @@
+package expr
+
+import (
+	"sync"
+)
+
+// CacheStats the stats of a cache
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Size      int
+}
+
+// CachedParser a parser with a bounded lru cache of the parsed exprs keyed by the
+// input, it's safe for concurrent use. The cache belongs to the parser, so the input
+// is parsed with the same config. The cached exprs are shared, the cb is called with
+// the cached vars if the input hits.
+type CachedParser struct {
+	mu     sync.Mutex
+	parser Parser
+	cache  *lru
+	stats  CacheStats
+}
+
+// NewCachedParser returns a cached parser caches at most size exprs
+func NewCachedParser(parser Parser, size int) *CachedParser {
+	return &CachedParser{
+		parser: parser,
+		cache:  newLRU(size),
+	}
+}
+
+// Parse returns the cached expr of the input, or parses and caches the expr
+func (p *CachedParser) Parse(input []byte, cb func(Expr)) (Expr, error) {
+	key := string(input)
+
+	p.mu.Lock()
+	value, ok := p.cache.get(key)
+	if ok {
+		p.stats.Hits++
+	} else {
+		p.stats.Misses++
+	}
+	p.mu.Unlock()
+
+	if ok {
+		expr := value.(Expr)
+		if cb != nil {
+			walk(expr, func(e Expr) {
+				if v, ok := e.(*varExpr); ok {
+					cb(v.expr)
+				}
+			})
+		}
+		return expr, nil
+	}
+
+	expr, err := p.parser.Parse(input, cb)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.stats.Evictions += uint64(p.cache.add(key, expr))
+	p.mu.Unlock()
+	return expr, nil
+}
+
+// Stats returns the stats of the cache
+func (p *CachedParser) Stats() CacheStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := p.stats
+	stats.Size = p.cache.len()
+	return stats
+}
+
+func (p *CachedParser) template() *parserTemplate {
+	t, _ := templateOf(p.parser)
+	return t
+}
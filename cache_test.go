@@ -0,0 +1,92 @@
+package expr
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU(t *testing.T) {
+	c := newLRU(2)
+	assert.Equal(t, 0, c.add("a", 1), "TestLRU failed")
+	assert.Equal(t, 0, c.add("b", 2), "TestLRU failed")
+
+	value, ok := c.get("a")
+	assert.True(t, ok, "TestLRU failed")
+	assert.Equal(t, 1, value, "TestLRU failed")
+
+	assert.Equal(t, 1, c.add("c", 3), "TestLRU failed")
+	_, ok = c.get("b")
+	assert.False(t, ok, "TestLRU failed")
+	assert.Equal(t, 2, c.len(), "TestLRU failed")
+
+	assert.Equal(t, 1, c.resize(1), "TestLRU failed")
+	_, ok = c.get("c")
+	assert.True(t, ok, "TestLRU failed")
+}
+
+func TestCachedParser(t *testing.T) {
+	p := NewCachedParser(NewParser(testVarFactory,
+		WithOp("+", testAdd),
+		WithVarType("num:", Num)), 2)
+
+	ctx := make(map[string]string)
+	ctx["a"] = "1"
+
+	var vars []Expr
+	cb := func(v Expr) { vars = append(vars, v) }
+
+	expr, err := p.Parse([]byte("{num:a}+1"), cb)
+	assert.NoError(t, err, "TestCachedParser failed")
+	cached, err := p.Parse([]byte("{num:a}+1"), cb)
+	assert.NoError(t, err, "TestCachedParser failed")
+	assert.True(t, expr == cached, "TestCachedParser failed")
+	assert.Equal(t, 2, len(vars), "TestCachedParser failed")
+	assert.True(t, vars[0] == vars[1], "TestCachedParser failed")
+
+	value, err := cached.Exec(ctx)
+	assert.NoError(t, err, "TestCachedParser failed")
+	assert.Equal(t, int64(2), value, "TestCachedParser failed")
+
+	_, err = p.Parse([]byte("{num:a}+2"), nil)
+	assert.NoError(t, err, "TestCachedParser failed")
+	_, err = p.Parse([]byte("{num:a}+3"), nil)
+	assert.NoError(t, err, "TestCachedParser failed")
+	_, err = p.Parse([]byte("{num:a"), nil)
+	assert.Error(t, err, "TestCachedParser failed")
+
+	stats := p.Stats()
+	assert.Equal(t, uint64(1), stats.Hits, "TestCachedParser failed")
+	assert.Equal(t, uint64(4), stats.Misses, "TestCachedParser failed")
+	assert.Equal(t, uint64(1), stats.Evictions, "TestCachedParser failed")
+	assert.Equal(t, 2, stats.Size, "TestCachedParser failed")
+
+	data, err := MarshalExpr(expr)
+	assert.NoError(t, err, "TestCachedParser failed")
+	_, err = UnmarshalExpr(p, data)
+	assert.NoError(t, err, "TestCachedParser failed")
+}
+
+func TestCachedParserConcurrent(t *testing.T) {
+	p := NewCachedParser(NewParser(testVarFactory,
+		WithOp("+", testAdd),
+		WithVarType("num:", Num)), 8)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, err := p.Parse([]byte("{num:a}+1"), nil)
+				assert.NoError(t, err, "TestCachedParserConcurrent failed")
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := p.Stats()
+	assert.Equal(t, uint64(800), stats.Hits+stats.Misses, "TestCachedParserConcurrent failed")
+	assert.Equal(t, 1, stats.Size, "TestCachedParserConcurrent failed")
+}
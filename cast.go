@@ -0,0 +1,85 @@
+package expr
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// castExpr converts the value of the expr to the var type, e.g. `num("42")`
+type castExpr struct {
+	source
+	varType VarType
+	span    Span
+	expr    Expr
+}
+
+func (expr *castExpr) Exec(ctx interface{}) (interface{}, error) {
+	value, err := expr.expr.Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return castValue(value, expr.varType)
+}
+
+func (expr *castExpr) ExecContext(ctx context.Context, env interface{}) (interface{}, error) {
+	value, err := ExecContext(ctx, expr.expr, env)
+	if err != nil {
+		return nil, err
+	}
+
+	return castValue(value, expr.varType)
+}
+
+// castNames returns the names of the var types can be cast to, e.g. `num(`
+func castNames() map[string]VarType {
+	names := make(map[string]VarType, len(varTypeNames))
+	for varType, name := range varTypeNames {
+		if varType != Any {
+			names[name] = varType
+		}
+	}
+
+	return names
+}
+
+// castValue converts the value to the var type by the rules of ValueByType, the value
+// is formatted as the text first
+func castValue(value interface{}, varType VarType) (interface{}, error) {
+	if typeOfValue(value) == varType {
+		return value, nil
+	}
+
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case int64:
+		text = strconv.FormatInt(v, 10)
+	case bool:
+		text = strconv.FormatBool(v)
+	case []string:
+		text = strings.Join(v, ",")
+	case *Set:
+		text = strings.Join(v.Values(), ",")
+	case fmt.Stringer:
+		text = v.String()
+	default:
+		return nil, fmt.Errorf("cast %T to %s not support", value, varType)
+	}
+
+	if text == "" {
+		// the empty value is the default value of the type, ValueByType returns
+		// the empty string for some types
+		return defaultValue(varType), nil
+	}
+
+	result, err := ValueByType([]byte(text), varType)
+	if err != nil {
+		return nil, fmt.Errorf("cast %q to %s failed: %w", text, varType, err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,140 @@
+package expr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCastValue(t *testing.T) {
+	value, err := castValue("42", Num)
+	assert.NoError(t, err, "TestCastValue failed")
+	assert.Equal(t, int64(42), value, "TestCastValue failed")
+
+	value, err = castValue(int64(42), Str)
+	assert.NoError(t, err, "TestCastValue failed")
+	assert.Equal(t, "42", value, "TestCastValue failed")
+
+	value, err = castValue("a,b", Array)
+	assert.NoError(t, err, "TestCastValue failed")
+	assert.Equal(t, []string{"a", "b"}, value, "TestCastValue failed")
+
+	value, err = castValue(true, Str)
+	assert.NoError(t, err, "TestCastValue failed")
+	assert.Equal(t, "true", value, "TestCastValue failed")
+
+	value, err = castValue("true", Bool)
+	assert.NoError(t, err, "TestCastValue failed")
+	assert.Equal(t, true, value, "TestCastValue failed")
+
+	_, err = castValue("abc", Num)
+	assert.Error(t, err, "TestCastValue failed")
+	assert.Contains(t, err.Error(), `cast "abc" to num failed`, "TestCastValue failed")
+
+	_, err = castValue(1.5, Num)
+	assert.Error(t, err, "TestCastValue failed")
+
+	// the empty values are the default values of the type
+	value, err = castValue("", Num)
+	assert.NoError(t, err, "TestCastValue failed")
+	assert.Equal(t, int64(0), value, "TestCastValue failed")
+
+	value, err = castValue("", Regexp)
+	assert.NoError(t, err, "TestCastValue failed")
+	assert.Equal(t, Regexp, typeOfValue(value), "TestCastValue failed")
+
+	value, err = castValue([]string{}, Num)
+	assert.NoError(t, err, "TestCastValue failed")
+	assert.Equal(t, int64(0), value, "TestCastValue failed")
+}
+
+func TestParserWithCast(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("+", testAdd),
+		WithOp("==", testEqual),
+		WithOp("===", testStrEqual),
+		WithOp("&&", testAndLogic),
+		WithVarType("num:", Num),
+		WithVarType("str:", Str),
+		WithTypeCheck())
+
+	ctx := make(map[string]string)
+	ctx["a"] = "1"
+	ctx["b"] = "40"
+
+	input := `(num("42") == (num({str:b}) + 2)) && (str({num:a} + 1) === "2") && (num(5) == 5)`
+	expr, err := p.Parse([]byte(input), nil)
+	assert.NoError(t, err, "TestParserWithCast failed")
+
+	value, err := expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithCast failed")
+	assert.Equal(t, true, value, "TestParserWithCast failed")
+
+	value, err = ExecContext(context.Background(), expr, ctx)
+	assert.NoError(t, err, "TestParserWithCast failed")
+	assert.Equal(t, true, value, "TestParserWithCast failed")
+
+	prog, err := Compile(expr)
+	assert.NoError(t, err, "TestParserWithCast failed")
+	value, err = prog.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithCast failed")
+	assert.Equal(t, true, value, "TestParserWithCast failed")
+
+	trace, err := Explain(expr, ctx)
+	assert.NoError(t, err, "TestParserWithCast failed")
+	assert.Equal(t, true, trace.Value, "TestParserWithCast failed")
+
+	data, err := EncodeExpr(expr)
+	assert.NoError(t, err, "TestParserWithCast failed")
+	decoded, err := DecodeExpr(p, data)
+	assert.NoError(t, err, "TestParserWithCast failed")
+	value, err = decoded.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithCast failed")
+	assert.Equal(t, true, value, "TestParserWithCast failed")
+
+	data, err = MarshalExpr(expr)
+	assert.NoError(t, err, "TestParserWithCast failed")
+	decoded, err = UnmarshalExpr(p, data)
+	assert.NoError(t, err, "TestParserWithCast failed")
+	value, err = decoded.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithCast failed")
+	assert.Equal(t, true, value, "TestParserWithCast failed")
+
+	var casts []string
+	walk(expr, func(e Expr) {
+		if c, ok := e.(*castExpr); ok {
+			casts = append(casts, c.text)
+			assert.Equal(t, c.text, input[c.span.Start:c.span.End], "TestParserWithCast failed")
+		}
+	})
+	assert.Equal(t, []string{`num("42")`, `num({str:b})`, `str({num:a} + 1)`, `num(5)`}, casts, "TestParserWithCast failed")
+
+	expr, err = p.Parse([]byte(`num({str:a}) + 1`), nil)
+	assert.NoError(t, err, "TestParserWithCast failed")
+	ctx["a"] = "x"
+	_, err = expr.Exec(ctx)
+	assert.Error(t, err, "TestParserWithCast failed")
+
+	expr, err = p.Parse([]byte(`num("") == 0`), nil)
+	assert.NoError(t, err, "TestParserWithCast failed")
+	value, err = expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithCast failed")
+	assert.Equal(t, true, value, "TestParserWithCast failed")
+
+	expr, err = p.Parse([]byte(`str({num:a})`), nil)
+	assert.NoError(t, err, "TestParserWithCast failed")
+	varType, err := Check(expr)
+	assert.NoError(t, err, "TestParserWithCast failed")
+	assert.Equal(t, Str, varType, "TestParserWithCast failed")
+
+	tokens, err := Tokenize(p, []byte(`enum(1) + num(1)`))
+	assert.NoError(t, err, "TestParserWithCast failed")
+	assert.Equal(t, KindText, tokens[0].Kind, "TestParserWithCast failed")
+	assert.Equal(t, KindLeftParen, tokens[1].Kind, "TestParserWithCast failed")
+	assert.Equal(t, KindCast, tokens[5].Kind, "TestParserWithCast failed")
+	assert.Equal(t, "num(", tokens[5].Text, "TestParserWithCast failed")
+
+	_, err = p.Parse([]byte(`num() + 1`), nil)
+	assert.Error(t, err, "TestParserWithCast failed")
+}
@@ -0,0 +1,158 @@
+package expr
+
+import (
+	"fmt"
+)
+
+// Signature the operand types and the result type of a op
+type Signature struct {
+	Left   VarType
+	Right  VarType
+	Result VarType
+}
+
+func (s Signature) match(left, right VarType) bool {
+	return matchType(s.Left, left) && matchType(s.Right, right)
+}
+
+func matchType(expect, actual VarType) bool {
+	return expect == Any || actual == Any || expect == actual
+}
+
+// TypeError the operand types mismatch all the signatures of the op
+type TypeError struct {
+	Op    string
+	Span  Span
+	Left  VarType
+	Right VarType
+}
+
+func (err *TypeError) Error() string {
+	return fmt.Sprintf("op <%s> at %d not support (%s, %s)",
+		err.Op,
+		err.Span.Start,
+		err.Left,
+		err.Right)
+}
+
+// Check checks the operand types of all the ops in the expr against the signatures
+// declared by WithOpSignature, and returns the result type of the expr. The vars use
+// the var type, the op without signatures accept any operands and returns Any.
+func Check(expr Expr) (VarType, error) {
+	c := &checker{}
+	return c.check(expr)
+}
+
+type checker struct {
+	// bind bind the overloads of the ops if the operand types are known
+	bind bool
+}
+
+func (c *checker) check(expr Expr) (VarType, error) {
+	switch e := expr.(type) {
+	case *node:
+		return c.checkNode(e)
+	case *varExpr:
+		return e.varType, nil
+	case *castExpr:
+		if _, err := c.check(e.expr); err != nil {
+			return Any, err
+		}
+		return e.varType, nil
+	case *macroExpr:
+		// the macro expr is shared and bound by the parser of the macro
+		return (&checker{}).check(e.expr)
+	case *constString:
+		return Str, nil
+	case *constInt64:
+		return Num, nil
+	case *constRegexp:
+		return Regexp, nil
+	case *constArray:
+		return Array, nil
+	case *constGlob:
+		return Glob, nil
+	case *constValue:
+		return typeOfValue(e.value), nil
+	default:
+		return Any, nil
+	}
+}
+
+func (c *checker) checkNode(n *node) (VarType, error) {
+	left, err := c.check(n.exprs[0])
+	if err != nil {
+		return Any, err
+	}
+
+	for idx, op := range n.ops {
+		right, err := c.check(n.exprs[idx+1])
+		if err != nil {
+			return Any, err
+		}
+
+		if c.bind && left != Any && right != Any {
+			if o := op.bind(left, right); o != nil {
+				n.fns[idx] = op.calcFunc(o.fn)
+			}
+		}
+
+		left, err = checkOp(op, n.opSpans[idx], left, right)
+		if err != nil {
+			return Any, err
+		}
+	}
+
+	return left, nil
+}
+
+func checkOp(op *operator, span Span, left, right VarType) (VarType, error) {
+	if len(op.signatures) == 0 {
+		return Any, nil
+	}
+
+	result := Any
+	found := false
+	for _, s := range op.signatures {
+		if !s.match(left, right) {
+			continue
+		}
+
+		if !found {
+			result = s.Result
+			found = true
+		} else if result != s.Result {
+			result = Any
+		}
+	}
+
+	if !found {
+		return Any, &TypeError{
+			Op:    op.symbol,
+			Span:  span,
+			Left:  left,
+			Right: right,
+		}
+	}
+
+	return result, nil
+}
+
+func typeOfValue(value interface{}) VarType {
+	switch value.(type) {
+	case string:
+		return Str
+	case int64:
+		return Num
+	case *GlobPattern:
+		return Glob
+	case Matcher:
+		return Regexp
+	case bool:
+		return Bool
+	case []string, *Set:
+		return Array
+	default:
+		return Any
+	}
+}
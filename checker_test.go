@@ -0,0 +1,78 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("+", testAdd),
+		WithOp("==", testEqual),
+		WithOp("&&", testAndLogic),
+		WithOp("~", testMatch),
+		WithOpSignature("+", Signature{Left: Num, Right: Num, Result: Num}),
+		WithOpSignature("==", Signature{Left: Num, Right: Num, Result: Bool}),
+		WithOpSignature("&&", Signature{Left: Bool, Right: Bool, Result: Bool}),
+		WithVarType("num:", Num),
+		WithVarType("str:", Str),
+		WithVarType("bool:", Bool))
+
+	expr, err := p.Parse([]byte("({num:a}+1==2)&&(3==3)"), nil)
+	assert.NoError(t, err, "TestCheck failed")
+	typ, err := Check(expr)
+	assert.NoError(t, err, "TestCheck failed")
+	assert.Equal(t, Bool, typ, "TestCheck failed")
+
+	expr, err = p.Parse([]byte(`"abc" + 1`), nil)
+	assert.NoError(t, err, "TestCheck failed")
+	_, err = Check(expr)
+	assert.Error(t, err, "TestCheck failed")
+	assert.Equal(t, "+", err.(*TypeError).Op, "TestCheck failed")
+	assert.Equal(t, 6, err.(*TypeError).Span.Start, "TestCheck failed")
+	assert.Equal(t, Str, err.(*TypeError).Left, "TestCheck failed")
+	assert.Equal(t, Num, err.(*TypeError).Right, "TestCheck failed")
+
+	// the bare bools are the bool constants with WithTypeCheck
+	tp := NewParser(testVarFactory,
+		WithOp("&&", testAndLogic),
+		WithOpSignature("&&", Signature{Left: Bool, Right: Bool, Result: Bool}),
+		WithVarType("str:", Str),
+		WithVarType("bool:", Bool),
+		WithTypeCheck())
+	_, err = tp.Parse([]byte("{str:a} && true"), nil)
+	assert.Error(t, err, "TestCheck failed")
+	assert.Equal(t, Str, err.(*TypeError).Left, "TestCheck failed")
+	assert.Equal(t, Bool, err.(*TypeError).Right, "TestCheck failed")
+
+	expr, err = tp.Parse([]byte("{bool:a} && true"), nil)
+	assert.NoError(t, err, "TestCheck failed")
+	typ, err = Check(expr)
+	assert.NoError(t, err, "TestCheck failed")
+	assert.Equal(t, Bool, typ, "TestCheck failed")
+	value, err := expr.Exec(map[string]string{"a": "true"})
+	assert.NoError(t, err, "TestCheck failed")
+	assert.Equal(t, true, value, "TestCheck failed")
+
+	expr, err = p.Parse([]byte("{str:a}~|a+|"), nil)
+	assert.NoError(t, err, "TestCheck failed")
+	typ, err = Check(expr)
+	assert.NoError(t, err, "TestCheck failed")
+	assert.Equal(t, Any, typ, "TestCheck failed")
+}
+
+func TestParseWithTypeCheck(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("+", testAdd),
+		WithOpSignature("+", Signature{Left: Num, Right: Num, Result: Num}),
+		WithVarType("num:", Num),
+		WithVarType("str:", Str),
+		WithTypeCheck())
+
+	_, err := p.Parse([]byte("{num:a}+1"), nil)
+	assert.NoError(t, err, "TestParseWithTypeCheck failed")
+
+	_, err = p.Parse([]byte("{str:a}+1"), nil)
+	assert.Error(t, err, "TestParseWithTypeCheck failed")
+}
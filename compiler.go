@@ -0,0 +1,228 @@
+package expr
+
+import (
+	"context"
+	"fmt"
+)
+
+type opcode byte
+
+const (
+	opConst opcode = iota
+	opVar
+	opInt64
+	opInt64Cmp
+	opStrCmp
+	opJumpIfFalse
+	opJumpIfTrue
+	opAssertBool
+	opPop
+	opCall
+	opCast
+)
+
+type instruction struct {
+	code opcode
+	arg  int
+}
+
+type call struct {
+	fn    CalcFunc
+	ctxFn ContextCalcFunc
+	right Expr
+}
+
+// Program the compiled bytecode of a expr, the ops added by WithInt64Op, WithInt64CmpOp,
+// WithStrCmpOp, WithAndOp and WithOrOp are executed by the VM without boxing, other ops
+// are called with the right expr as CalcFunc does.
+type Program struct {
+	code      []instruction
+	consts    []value
+	vars      []Expr
+	calls     []call
+	int64Fns  []func(int64, int64) int64
+	int64Cmps []func(int64, int64) bool
+	strCmps   []func(string, string) bool
+	maxStack  int
+	// depth the max nesting depth of the compiled nodes
+	depth  int
+	budget *Budget
+}
+
+// Compile compiles the expr returned by Parser to a program
+func Compile(expr Expr) (*Program, error) {
+	c := &compiler{
+		prog: &Program{},
+	}
+
+	if _, err := c.compile(expr); err != nil {
+		return nil, err
+	}
+
+	if n, ok := expr.(*node); ok {
+		c.prog.budget = n.budget
+	}
+
+	return c.prog, nil
+}
+
+// Exec executes the program with a pooled VM
+func (prog *Program) Exec(ctx interface{}) (interface{}, error) {
+	if prog.budget != nil {
+		return prog.ExecContext(context.Background(), ctx)
+	}
+
+	vm := vmPool.Get().(*VM)
+	value, err := vm.Run(prog, ctx)
+	vmPool.Put(vm)
+	return value, err
+}
+
+type compiler struct {
+	prog    *Program
+	depth   int
+	nesting int
+}
+
+func (c *compiler) emit(code opcode, arg int) int {
+	c.prog.code = append(c.prog.code, instruction{code: code, arg: arg})
+	return len(c.prog.code) - 1
+}
+
+func (c *compiler) push(code opcode, arg int) {
+	c.emit(code, arg)
+	c.depth++
+	if c.depth > c.prog.maxStack {
+		c.prog.maxStack = c.depth
+	}
+}
+
+func (c *compiler) pushConst(v value) VarType {
+	c.prog.consts = append(c.prog.consts, v)
+	c.push(opConst, len(c.prog.consts)-1)
+	return v.kind
+}
+
+func (c *compiler) compile(expr Expr) (VarType, error) {
+	switch e := expr.(type) {
+	case *node:
+		return c.compileNode(e)
+	case *varExpr:
+		c.prog.vars = append(c.prog.vars, e)
+		c.push(opVar, len(c.prog.vars)-1)
+		return e.varType, nil
+	case *castExpr:
+		if _, err := c.compile(e.expr); err != nil {
+			return Any, err
+		}
+		c.emit(opCast, int(e.varType))
+		return e.varType, nil
+	case *macroExpr:
+		return c.compile(e.expr)
+	case *constString:
+		return c.pushConst(value{kind: Str, s: e.value, v: e.value}), nil
+	case *constInt64:
+		return c.pushConst(value{kind: Num, i: e.value, v: e.value}), nil
+	case *constRegexp:
+		return c.pushConst(value{kind: Regexp, v: e.value}), nil
+	case *constArray:
+		v, _ := e.Exec(nil)
+		return c.pushConst(value{kind: Array, v: v}), nil
+	case *constGlob:
+		return c.pushConst(value{kind: Glob, v: e.value}), nil
+	case *constValue:
+		return c.pushConst(toValue(e.value)), nil
+	case nil:
+		return Any, fmt.Errorf("compile nil expr")
+	default:
+		c.prog.vars = append(c.prog.vars, e)
+		c.push(opVar, len(c.prog.vars)-1)
+		return Any, nil
+	}
+}
+
+func (c *compiler) compileNode(n *node) (VarType, error) {
+	c.nesting++
+	defer func() { c.nesting-- }()
+	if c.nesting > c.prog.depth {
+		c.prog.depth = c.nesting
+	}
+
+	left, err := c.compile(n.exprs[0])
+	if err != nil {
+		return Any, err
+	}
+
+	for idx, op := range n.ops {
+		right := n.exprs[idx+1]
+		rightType, err := Check(right)
+		if err != nil {
+			rightType = Any
+		}
+
+		o := c.choose(op, left, rightType)
+		if o == nil || o.native == nil {
+			c.prog.calls = append(c.prog.calls, call{fn: n.fns[idx], ctxFn: op.ctxFn, right: right})
+			c.emit(opCall, len(c.prog.calls)-1)
+			left, err = checkOp(op, n.opSpans[idx], left, rightType)
+			if err != nil {
+				left = Any
+			}
+			continue
+		}
+
+		if kind, ok := o.native.(logicKind); ok {
+			code := opJumpIfFalse
+			if kind == logicOr {
+				code = opJumpIfTrue
+			}
+
+			jump := c.emit(code, 0)
+			c.emit(opPop, 0)
+			c.depth--
+			if _, err := c.compile(right); err != nil {
+				return Any, err
+			}
+			c.emit(opAssertBool, 0)
+			c.prog.code[jump].arg = len(c.prog.code)
+			left = Bool
+			continue
+		}
+
+		if _, err := c.compile(right); err != nil {
+			return Any, err
+		}
+
+		switch fn := o.native.(type) {
+		case func(int64, int64) int64:
+			c.prog.int64Fns = append(c.prog.int64Fns, fn)
+			c.emit(opInt64, len(c.prog.int64Fns)-1)
+		case func(int64, int64) bool:
+			c.prog.int64Cmps = append(c.prog.int64Cmps, fn)
+			c.emit(opInt64Cmp, len(c.prog.int64Cmps)-1)
+		case func(string, string) bool:
+			c.prog.strCmps = append(c.prog.strCmps, fn)
+			c.emit(opStrCmp, len(c.prog.strCmps)-1)
+		default:
+			return Any, fmt.Errorf("op <%s> with native %T not support", op.symbol, fn)
+		}
+		c.depth--
+		left = o.signature.Result
+	}
+
+	return left, nil
+}
+
+// choose returns the overload used by the compiled program, the only one overload
+// is used if the operand types are unknown, the VM checks the operand values
+func (c *compiler) choose(op *operator, left, right VarType) *overload {
+	if left != Any && right != Any {
+		return op.bind(left, right)
+	}
+
+	if len(op.overloads) == 1 && op.fallback == nil {
+		return &op.overloads[0]
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package expr
+
+import (
+	"context"
+)
+
+// ContextExpr a expr can observe the context, e.g. cancellation and deadline
+type ContextExpr interface {
+	Expr
+	ExecContext(context.Context, interface{}) (interface{}, error)
+}
+
+// ContextCalcFunc a calc function with the context, the right expr executed by
+// the function observes the context too.
+type ContextCalcFunc func(context.Context, interface{}, Expr, interface{}) (interface{}, error)
+
+// ExecContext executes the expr with the context, the expr returned by Parser checks
+// the context between op steps and passes it to the vars implemented ContextExpr and
+// the ops added by WithContextOp.
+func ExecContext(ctx context.Context, expr Expr, env interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if e, ok := expr.(ContextExpr); ok {
+		return e.ExecContext(ctx, env)
+	}
+
+	return expr.Exec(env)
+}
+
+// contextExpr binds the context to the expr, so that the CalcFunc executes the
+// right expr with the context.
+type contextExpr struct {
+	ctx  context.Context
+	expr Expr
+}
+
+func (expr *contextExpr) Exec(env interface{}) (interface{}, error) {
+	return ExecContext(expr.ctx, expr.expr, env)
+}
+
+func bindContext(ctx context.Context, expr Expr) Expr {
+	switch expr.(type) {
+	case *node, *varExpr, *castExpr, *macroExpr:
+		return &contextExpr{ctx: ctx, expr: expr}
+	default:
+		return expr
+	}
+}
+
+func (n *node) ExecContext(ctx context.Context, env interface{}) (interface{}, error) {
+	ctx = withTracker(ctx, n.budget)
+	t := trackerFrom(ctx)
+	if t != nil {
+		if err := t.enter(1); err != nil {
+			return nil, err
+		}
+		defer t.leave(1)
+	}
+
+	left, err := ExecContext(ctx, n.exprs[0], env)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, right := range n.exprs[1:] {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if err := t.addOp(); err != nil {
+			return nil, err
+		}
+
+		if fn := n.ops[idx].ctxFn; fn != nil {
+			left, err = fn(ctx, left, bindContext(ctx, right), env)
+		} else {
+			left, err = n.fns[idx](left, bindContext(ctx, right), env)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return left, nil
+}
+
+func (expr *varExpr) ExecContext(ctx context.Context, env interface{}) (interface{}, error) {
+	value, err := ExecContext(ctx, expr.expr, env)
+	if err != nil {
+		return nil, err
+	}
+
+	if t := trackerFrom(ctx); t != nil {
+		if err := t.budget.checkValue(value); err != nil {
+			return nil, err
+		}
+	}
+
+	return value, nil
+}
+
+// ExecContext executes the program with a pooled VM and the context
+func (prog *Program) ExecContext(ctx context.Context, env interface{}) (interface{}, error) {
+	ctx = withTracker(ctx, prog.budget)
+	vm := vmPool.Get().(*VM)
+	value, err := vm.RunContext(ctx, prog, env)
+	vmPool.Put(vm)
+	return value, err
+}
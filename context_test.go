@@ -0,0 +1,83 @@
+package expr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testSlowVarExpr struct {
+	testMapBasedVarExpr
+}
+
+func (expr *testSlowVarExpr) ExecContext(ctx context.Context, env interface{}) (interface{}, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(time.Second):
+		return expr.Exec(env)
+	}
+}
+
+func testSlowVarFactory(value []byte, valueType VarType) (Expr, error) {
+	return &testSlowVarExpr{
+		testMapBasedVarExpr: testMapBasedVarExpr{
+			valueType: valueType,
+			attr:      string(value),
+		},
+	}, nil
+}
+
+func TestExecContext(t *testing.T) {
+	p := NewParser(testSlowVarFactory,
+		WithOp("+", testAdd),
+		WithOp("==", testEqual),
+		WithOp("&&", testAndLogic),
+		WithVarType("num:", Num))
+
+	ctx := make(map[string]string)
+	ctx["1"] = "1"
+
+	expr, err := p.Parse([]byte("(1==1)&&({num:1}+1==2)"), nil)
+	assert.NoError(t, err, "TestExecContext failed")
+
+	c, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	_, err = ExecContext(c, expr, ctx)
+	assert.Equal(t, context.DeadlineExceeded, err, "TestExecContext failed")
+
+	prog, err := Compile(expr)
+	assert.NoError(t, err, "TestExecContext failed")
+	_, err = prog.ExecContext(c, ctx)
+	assert.Equal(t, context.DeadlineExceeded, err, "TestExecContext failed")
+}
+
+func TestWithContextOp(t *testing.T) {
+	type key struct{}
+
+	p := NewParser(testVarFactory,
+		WithContextOp("+", func(ctx context.Context, left interface{}, right Expr, env interface{}) (interface{}, error) {
+			value, err := testAdd(left, right, env)
+			if err != nil {
+				return nil, err
+			}
+
+			if delta, ok := ctx.Value(key{}).(int64); ok {
+				return value.(int64) + delta, nil
+			}
+			return value, nil
+		}))
+
+	expr, err := p.Parse([]byte("1+2"), nil)
+	assert.NoError(t, err, "TestWithContextOp failed")
+
+	value, err := expr.Exec(nil)
+	assert.NoError(t, err, "TestWithContextOp failed")
+	assert.Equal(t, int64(3), value, "TestWithContextOp failed")
+
+	value, err = ExecContext(context.WithValue(context.Background(), key{}, int64(10)), expr, nil)
+	assert.NoError(t, err, "TestWithContextOp failed")
+	assert.Equal(t, int64(13), value, "TestWithContextOp failed")
+}
@@ -0,0 +1,39 @@
+package expr
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fagongzi/util/hack"
+)
+
+// NewEnvVarExprFactory returns a factory that resolves the vars with the prefix from the
+// process environment variables at parse time, e.g. `{num:env.MAX_RETRIES}` with prefix `env.`
+// becomes the constant value of `MAX_RETRIES`, it's folded like the consts and it's not a var
+// of Variables or the parse callback. Other vars are created by the next factory.
+func NewEnvVarExprFactory(prefix string, next VarExprFactory) VarExprFactory {
+	return func(value []byte, varType VarType) (Expr, error) {
+		name := hack.SliceToString(value)
+		if !strings.HasPrefix(name, prefix) {
+			if next == nil {
+				return nil, fmt.Errorf("var %s not support", name)
+			}
+
+			return next(value, varType)
+		}
+
+		name = name[len(prefix):]
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("env %s not set", name)
+		}
+
+		v, err := ValueByType([]byte(env), varType)
+		if err != nil {
+			return nil, err
+		}
+
+		return newValueExpr(v), nil
+	}
+}
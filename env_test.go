@@ -0,0 +1,46 @@
+package expr
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvVarExprFactory(t *testing.T) {
+	os.Setenv("EXPR_TEST_MAX", "10")
+	defer os.Unsetenv("EXPR_TEST_MAX")
+
+	p := NewParser(NewEnvVarExprFactory("env.", testVarFactory),
+		WithOp("+", testAdd),
+		WithOp("==", testEqual),
+		WithVarType("num:", Num))
+
+	ctx := make(map[string]string)
+	ctx["1"] = "9"
+
+	var vars []Expr
+	expr, err := p.Parse([]byte("{num:1}+1=={num:env.EXPR_TEST_MAX}"), func(v Expr) { vars = append(vars, v) })
+	assert.NoError(t, err, "TestEnvVarExprFactory failed")
+	assert.Equal(t, 1, len(vars), "TestEnvVarExprFactory failed")
+	assert.Equal(t, 1, len(Variables(expr)), "TestEnvVarExprFactory failed")
+	value, err := expr.Exec(ctx)
+	assert.NoError(t, err, "TestEnvVarExprFactory failed")
+	assert.Equal(t, true, value, "TestEnvVarExprFactory failed")
+
+	// the env values are folded like the consts
+	p = NewParser(NewEnvVarExprFactory("env.", testVarFactory),
+		WithOp("+", testAdd, Pure),
+		WithVarType("num:", Num))
+	expr, err = p.Parse([]byte("{num:env.EXPR_TEST_MAX}+1"), nil)
+	assert.NoError(t, err, "TestEnvVarExprFactory failed")
+	assert.Equal(t, 0, len(Variables(expr)), "TestEnvVarExprFactory failed")
+	assert.Equal(t, 1, len(expr.(*node).exprs), "TestEnvVarExprFactory failed")
+	assert.True(t, isConst(expr.(*node).exprs[0]), "TestEnvVarExprFactory failed")
+	value, err = expr.Exec(nil)
+	assert.NoError(t, err, "TestEnvVarExprFactory failed")
+	assert.Equal(t, int64(11), value, "TestEnvVarExprFactory failed")
+
+	_, err = p.Parse([]byte("{num:1}=={num:env.EXPR_TEST_MISSING}"), nil)
+	assert.Error(t, err, "TestEnvVarExprFactory failed")
+}
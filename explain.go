@@ -0,0 +1,262 @@
+package expr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Trace the evaluation trace of a expr
+type Trace struct {
+	// Text the source text of the expr
+	Text string
+	// Op the op applied to the value of the previous siblings and this expr
+	Op string
+	// Value the result value, the accumulated value of the chain after the op if Op is set
+	Value interface{}
+	// Right the value of this expr as the right operand of the op
+	Right interface{}
+	// Err the error returned by the expr
+	Err error
+	// ShortCircuited the expr is not executed by the op
+	ShortCircuited bool
+	// Children the traces of the sub exprs
+	Children []*Trace
+}
+
+// Explain executes the expr and returns the trace of every sub expr, the error is
+// the error returned by the expr. The operands of the Commutative ops are traced in
+// the evaluation order, it may be different from the input order.
+func Explain(expr Expr, ctx interface{}) (*Trace, error) {
+	t := explain(expr, ctx)
+	return t, t.Err
+}
+
+func explain(expr Expr, ctx interface{}) *Trace {
+	if c, ok := expr.(*castExpr); ok {
+		t := &Trace{Text: c.text}
+		sub := explain(c.expr, ctx)
+		t.Children = append(t.Children, sub)
+		if sub.Err != nil {
+			t.Err = sub.Err
+			return t
+		}
+
+		t.Value, t.Err = castValue(sub.Value, c.varType)
+		return t
+	}
+
+	if m, ok := expr.(*macroExpr); ok {
+		t := &Trace{Text: m.text}
+		sub := explain(m.expr, ctx)
+		t.Children = append(t.Children, sub)
+		t.Value, t.Err = sub.Value, sub.Err
+		return t
+	}
+
+	n, ok := expr.(*node)
+	if !ok {
+		t := &Trace{Text: textOf(expr)}
+		t.Value, t.Err = expr.Exec(ctx)
+		return t
+	}
+
+	// the node created by the var braces
+	if len(n.exprs) == 1 {
+		return explain(n.exprs[0], ctx)
+	}
+
+	t := &Trace{Text: n.text}
+	left := explain(n.exprs[0], ctx)
+	t.Children = append(t.Children, left)
+	if left.Err != nil {
+		t.Err = left.Err
+		return t
+	}
+
+	value := left.Value
+	for idx, right := range n.exprs[1:] {
+		child := &Trace{
+			Text: textOf(right),
+			Op:   n.ops[idx].symbol,
+		}
+		t.Children = append(t.Children, child)
+
+		// the consts are passed as the copies of the same type, so that the CalcFunc
+		// can type-assert them
+		child.ShortCircuited = true
+		operand := traceConst(right, child)
+		if operand == nil {
+			operand = &traceExpr{expr: right, trace: child}
+		}
+
+		var err error
+		value, err = n.fns[idx](value, operand, ctx)
+		if err != nil {
+			child.Err = err
+			t.Err = err
+			return t
+		}
+		child.Value = value
+	}
+
+	t.Value = value
+	return t
+}
+
+func textOf(expr Expr) string {
+	if s, ok := expr.(sourceExpr); ok && s.sourceText() != "" {
+		return s.sourceText()
+	}
+
+	return fmt.Sprintf("%T", expr)
+}
+
+// traceConst returns the copy of the const which records the trace when executed by
+// the CalcFunc, nil if the expr is not a const
+func traceConst(expr Expr, t *Trace) Expr {
+	var c Expr
+	switch e := expr.(type) {
+	case *constString:
+		v := *e
+		v.trace = t
+		c = &v
+	case *constInt64:
+		v := *e
+		v.trace = t
+		c = &v
+	case *constRegexp:
+		v := *e
+		v.trace = t
+		c = &v
+	case *constGlob:
+		v := *e
+		v.trace = t
+		c = &v
+	case *constValue:
+		v := *e
+		v.trace = t
+		c = &v
+	case *constArray:
+		v := *e
+		v.trace = t
+		c = &v
+	default:
+		return nil
+	}
+
+	t.Right, _ = expr.Exec(nil)
+	return c
+}
+
+// traceExpr records the trace of the right expr executed by the CalcFunc
+type traceExpr struct {
+	expr  Expr
+	trace *Trace
+}
+
+func (expr *traceExpr) Exec(ctx interface{}) (interface{}, error) {
+	t := explain(expr.expr, ctx)
+	expr.trace.ShortCircuited = false
+	expr.trace.Right = t.Value
+	expr.trace.Children = t.Children
+	return t.Value, t.Err
+}
+
+// String returns the trace as indented text
+func (t *Trace) String() string {
+	var buf bytes.Buffer
+	t.write(&buf, 0)
+	return buf.String()
+}
+
+func (t *Trace) write(buf *bytes.Buffer, depth int) {
+	buf.WriteString(strings.Repeat("  ", depth))
+	if t.Op != "" {
+		buf.WriteString(t.Op)
+		buf.WriteString(" ")
+	}
+	buf.WriteString(t.Text)
+	if t.Err != nil {
+		fmt.Fprintf(buf, " => error: %s", t.Err)
+	} else {
+		fmt.Fprintf(buf, " => %s", formatValue(t.Value))
+	}
+	if t.ShortCircuited {
+		buf.WriteString(" (right: <short-circuited>)")
+	} else if t.Op != "" && t.Err == nil {
+		fmt.Fprintf(buf, " (right: %s)", formatValue(t.Right))
+	}
+	buf.WriteString("\n")
+
+	for _, c := range t.Children {
+		c.write(buf, depth+1)
+	}
+}
+
+type jsonTrace struct {
+	Text           string       `json:"text"`
+	Op             string       `json:"op,omitempty"`
+	Value          interface{}  `json:"value"`
+	Right          *interface{} `json:"right,omitempty"`
+	Error          string       `json:"error,omitempty"`
+	ShortCircuited bool         `json:"short_circuited,omitempty"`
+	Children       []*jsonTrace `json:"children,omitempty"`
+}
+
+// MarshalJSON returns the trace as json, the regexp values are formatted as the patterns
+func (t *Trace) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.toJSON())
+}
+
+func (t *Trace) toJSON() *jsonTrace {
+	value := &jsonTrace{
+		Text:           t.Text,
+		Op:             t.Op,
+		ShortCircuited: t.ShortCircuited,
+	}
+
+	if t.Err != nil {
+		value.Error = t.Err.Error()
+	} else {
+		value.Value = jsonValue(t.Value)
+	}
+	if t.Op != "" && !t.ShortCircuited {
+		// the false, 0 and "" right values are kept
+		right := jsonValue(t.Right)
+		value.Right = &right
+	}
+
+	for _, c := range t.Children {
+		value.Children = append(value.Children, c.toJSON())
+	}
+	return value
+}
+
+// jsonValue returns the value can be marshaled, the regexps are formatted as the patterns
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case Matcher:
+		return v.String()
+	case *GlobPattern:
+		return v.String()
+	case *Set:
+		return v.Values()
+	default:
+		return v
+	}
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case *GlobPattern:
+		return fmt.Sprintf("#%s#", v.String())
+	case Matcher:
+		return fmt.Sprintf("|%s|", v.String())
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
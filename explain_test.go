@@ -0,0 +1,148 @@
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplain(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("+", testAdd),
+		WithOp("==", testEqual),
+		WithOp("&&", testAndLogic),
+		WithVarType("num:", Num))
+
+	ctx := make(map[string]string)
+	ctx["a"] = "1"
+
+	expr, err := p.Parse([]byte("({num:a}+1==3) && ({num:a}==1)"), nil)
+	assert.NoError(t, err, "TestExplain failed")
+
+	trace, err := Explain(expr, ctx)
+	assert.NoError(t, err, "TestExplain failed")
+	assert.Equal(t, false, trace.Value, "TestExplain failed")
+	assert.Equal(t, 2, len(trace.Children), "TestExplain failed")
+
+	left := trace.Children[0]
+	assert.Equal(t, "({num:a}+1==3)", left.Text, "TestExplain failed")
+	assert.Equal(t, false, left.Value, "TestExplain failed")
+	assert.Equal(t, 3, len(left.Children), "TestExplain failed")
+	assert.Equal(t, "{num:a}", left.Children[0].Text, "TestExplain failed")
+	assert.Equal(t, int64(1), left.Children[0].Value, "TestExplain failed")
+	assert.Equal(t, "+", left.Children[1].Op, "TestExplain failed")
+	assert.Equal(t, "1", left.Children[1].Text, "TestExplain failed")
+	assert.Equal(t, int64(1), left.Children[1].Right, "TestExplain failed")
+	assert.Equal(t, int64(2), left.Children[1].Value, "TestExplain failed")
+	assert.Equal(t, "==", left.Children[2].Op, "TestExplain failed")
+	assert.Equal(t, "3", left.Children[2].Text, "TestExplain failed")
+	assert.Equal(t, false, left.Children[2].Value, "TestExplain failed")
+
+	right := trace.Children[1]
+	assert.Equal(t, "&&", right.Op, "TestExplain failed")
+	assert.Equal(t, "({num:a}==1)", right.Text, "TestExplain failed")
+	assert.True(t, right.ShortCircuited, "TestExplain failed")
+	assert.Equal(t, false, right.Value, "TestExplain failed")
+
+	expect := `({num:a}+1==3) && ({num:a}==1) => false
+  ({num:a}+1==3) => false
+    {num:a} => 1
+    + 1 => 2 (right: 1)
+    == 3 => false (right: 3)
+  && ({num:a}==1) => false (right: <short-circuited>)
+`
+	assert.Equal(t, expect, trace.String(), "TestExplain failed")
+
+	data, err := json.Marshal(trace)
+	assert.NoError(t, err, "TestExplain failed")
+	assert.Contains(t, string(data), `"short_circuited":true`, "TestExplain failed")
+}
+
+func TestExplainWithError(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("+", testAdd),
+		WithVarType("str:", Str))
+
+	ctx := make(map[string]string)
+	ctx["a"] = "abc"
+
+	expr, err := p.Parse([]byte("1+{str:a}"), nil)
+	assert.NoError(t, err, "TestExplainWithError failed")
+
+	trace, err := Explain(expr, ctx)
+	assert.Error(t, err, "TestExplainWithError failed")
+	assert.Equal(t, err, trace.Err, "TestExplainWithError failed")
+	assert.Equal(t, "abc", trace.Children[1].Right, "TestExplainWithError failed")
+	assert.Equal(t, err, trace.Children[1].Err, "TestExplainWithError failed")
+}
+
+func TestExplainOperands(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("+", testAdd),
+		WithOp("==", testEqual),
+		WithOp("&&", testAndLogic),
+		WithOp("in", func(left interface{}, right Expr, ctx interface{}) (interface{}, error) {
+			if _, ok := right.(*constArray); !ok {
+				return nil, fmt.Errorf("%T is not array", right)
+			}
+			return testStrIn(left, right, ctx)
+		}),
+		WithVarType("num:", Num),
+		WithVarType("str:", Str))
+
+	ctx := make(map[string]string)
+	ctx["a"] = "1"
+	ctx["b"] = "2"
+	ctx["c"] = "x"
+
+	expr, err := p.Parse([]byte("({num:a}+{num:b}==3) && ({str:c} in [x,y])"), nil)
+	assert.NoError(t, err, "TestExplainOperands failed")
+
+	trace, err := Explain(expr, ctx)
+	assert.NoError(t, err, "TestExplainOperands failed")
+	assert.Equal(t, true, trace.Value, "TestExplainOperands failed")
+
+	expect := `({num:a}+{num:b}==3) && ({str:c} in [x,y]) => true
+  ({num:a}+{num:b}==3) => true
+    {num:a} => 1
+    + {num:b} => 3 (right: 2)
+    == 3 => true (right: 3)
+  && ({str:c} in [x,y]) => true (right: true)
+    {str:c} => "x"
+    in [x,y] => true (right: [x y])
+`
+	assert.Equal(t, expect, trace.String(), "TestExplainOperands failed")
+}
+
+func TestExplainConstShortCircuited(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithAndOp("&&"),
+		WithVarType("bool:", Bool),
+		WithTypeCheck())
+
+	ctx := make(map[string]string)
+	ctx["a"] = "false"
+
+	expr, err := p.Parse([]byte("{bool:a} && true"), nil)
+	assert.NoError(t, err, "TestExplainConstShortCircuited failed")
+
+	trace, err := Explain(expr, ctx)
+	assert.NoError(t, err, "TestExplainConstShortCircuited failed")
+	assert.Equal(t, false, trace.Value, "TestExplainConstShortCircuited failed")
+	assert.True(t, trace.Children[1].ShortCircuited, "TestExplainConstShortCircuited failed")
+	assert.Contains(t, trace.String(), "&& true => false (right: <short-circuited>)", "TestExplainConstShortCircuited failed")
+
+	// the false right value is in the json
+	ctx["a"] = "true"
+	expr, err = p.Parse([]byte("{bool:a} && false"), nil)
+	assert.NoError(t, err, "TestExplainConstShortCircuited failed")
+	trace, err = Explain(expr, ctx)
+	assert.NoError(t, err, "TestExplainConstShortCircuited failed")
+	assert.False(t, trace.Children[1].ShortCircuited, "TestExplainConstShortCircuited failed")
+
+	data, err := json.Marshal(trace)
+	assert.NoError(t, err, "TestExplainConstShortCircuited failed")
+	assert.Contains(t, string(data), `"value":false,"right":false}]`, "TestExplainConstShortCircuited failed")
+}
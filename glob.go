@@ -0,0 +1,125 @@
+package expr
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	globSeparator = "/"
+	globAny       = "**"
+)
+
+// GlobPattern the path glob pattern, the pattern is split into the segments by `/`, the
+// segment `**` matches zero or more segments, the other segments match one segment
+// by path.Match, e.g. `/api/*/users/**`
+type GlobPattern struct {
+	pattern  string
+	segments []string
+}
+
+// CompileGlob returns the glob of the pattern
+func CompileGlob(pattern string) (*GlobPattern, error) {
+	var segments []string
+	for _, segment := range strings.Split(pattern, globSeparator) {
+		if segment == globAny {
+			// the consecutive `**` are the same as one
+			if len(segments) == 0 || segments[len(segments)-1] != globAny {
+				segments = append(segments, segment)
+			}
+			continue
+		}
+
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+		segments = append(segments, segment)
+	}
+
+	return &GlobPattern{
+		pattern:  pattern,
+		segments: segments,
+	}, nil
+}
+
+// MustCompileGlob is like CompileGlob but panics if the pattern is invalid
+func MustCompileGlob(pattern string) *GlobPattern {
+	g, err := CompileGlob(pattern)
+	if err != nil {
+		panic(err)
+	}
+
+	return g
+}
+
+// MatchString returns true if the whole value matches the glob. It backtracks only to
+// the last `**`, so the time is O(len(segments) * len(value)) at most.
+func (g *GlobPattern) MatchString(value string) bool {
+	end := len(value) + 1
+	pi, pos := 0, 0
+	star, mark := -1, 0
+	for pos < end {
+		if pi < len(g.segments) && g.segments[pi] == globAny {
+			star, mark = pi, pos
+			pi++
+			continue
+		}
+
+		name, next := segmentAt(value, pos)
+		if pi < len(g.segments) && matchSegment(g.segments[pi], name) {
+			pi++
+			pos = next
+		} else if star >= 0 {
+			// the last `**` matches one more segment
+			_, mark = segmentAt(value, mark)
+			pi, pos = star+1, mark
+		} else {
+			return false
+		}
+	}
+
+	for pi < len(g.segments) && g.segments[pi] == globAny {
+		pi++
+	}
+	return pi == len(g.segments)
+}
+
+func (g *GlobPattern) String() string {
+	return g.pattern
+}
+
+// segmentAt returns the segment starts at the pos and the start of the next segment,
+// the next is len(value)+1 after the last segment
+func segmentAt(value string, pos int) (string, int) {
+	if idx := strings.IndexByte(value[pos:], globSeparator[0]); idx >= 0 {
+		return value[pos : pos+idx], pos + idx + 1
+	}
+
+	return value[pos:], len(value) + 1
+}
+
+func matchSegment(pattern, name string) bool {
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// MatchGlob the calc func matches the str left value with the glob right value
+func MatchGlob(left interface{}, right Expr, ctx interface{}) (interface{}, error) {
+	a, ok := left.(string)
+	if !ok {
+		return nil, fmt.Errorf("%+v is not string", left)
+	}
+
+	value, err := right.Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	g, ok := value.(*GlobPattern)
+	if !ok {
+		return nil, fmt.Errorf("%+v is not glob", value)
+	}
+
+	return g.MatchString(a), nil
+}
@@ -0,0 +1,115 @@
+package expr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileGlob(t *testing.T) {
+	g, err := CompileGlob("/api/*/users/**")
+	assert.NoError(t, err, "TestCompileGlob failed")
+	assert.Equal(t, "/api/*/users/**", g.String(), "TestCompileGlob failed")
+	assert.True(t, g.MatchString("/api/v1/users"), "TestCompileGlob failed")
+	assert.True(t, g.MatchString("/api/v1/users/1/orders"), "TestCompileGlob failed")
+	assert.False(t, g.MatchString("/api/v1/v2/users"), "TestCompileGlob failed")
+	assert.False(t, g.MatchString("/api/users"), "TestCompileGlob failed")
+
+	g, err = CompileGlob("/**/*.js")
+	assert.NoError(t, err, "TestCompileGlob failed")
+	assert.True(t, g.MatchString("/a.js"), "TestCompileGlob failed")
+	assert.True(t, g.MatchString("/static/js/a.js"), "TestCompileGlob failed")
+	assert.False(t, g.MatchString("/static/a.css"), "TestCompileGlob failed")
+
+	g, err = CompileGlob("/user?/[0-9]")
+	assert.NoError(t, err, "TestCompileGlob failed")
+	assert.True(t, g.MatchString("/users/1"), "TestCompileGlob failed")
+	assert.False(t, g.MatchString("/users/a"), "TestCompileGlob failed")
+
+	assert.True(t, MustCompileGlob("**").MatchString(""), "TestCompileGlob failed")
+	assert.True(t, MustCompileGlob("/a/**/b/**/c").MatchString("/a/x/b/y/b/z/c"), "TestCompileGlob failed")
+	assert.True(t, MustCompileGlob("/a/**/**/c").MatchString("/a/c"), "TestCompileGlob failed")
+	assert.False(t, MustCompileGlob("/a/**/b/**/c").MatchString("/a/x/b/y/d"), "TestCompileGlob failed")
+	assert.False(t, MustCompileGlob("/a").MatchString("/a/b"), "TestCompileGlob failed")
+
+	// the many `**` don't backtrack polynomially
+	long := strings.Repeat("/x", 10000)
+	assert.False(t, MustCompileGlob(strings.Repeat("/**/x", 50)+"/y").MatchString(long), "TestCompileGlob failed")
+
+	_, err = CompileGlob("/api/[a")
+	assert.Error(t, err, "TestCompileGlob failed")
+}
+
+func TestParserWithGlob(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithGlobOp("glob"),
+		WithOp("&&", testAndLogic),
+		WithVarType("str:", Str),
+		WithVarType("glob:", Glob),
+		WithTypeCheck())
+
+	ctx := make(map[string]string)
+	ctx["path"] = "/api/v1/users/1"
+	ctx["rule"] = "/api/**"
+
+	expr, err := p.Parse([]byte(`({str:path} glob #/api/*/users/**#) && ({str:path} glob {glob:rule})`), nil)
+	assert.NoError(t, err, "TestParserWithGlob failed")
+	value, err := expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithGlob failed")
+	assert.Equal(t, true, value, "TestParserWithGlob failed")
+
+	prog, err := Compile(expr)
+	assert.NoError(t, err, "TestParserWithGlob failed")
+	value, err = prog.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithGlob failed")
+	assert.Equal(t, true, value, "TestParserWithGlob failed")
+
+	data, err := EncodeExpr(expr)
+	assert.NoError(t, err, "TestParserWithGlob failed")
+	expr, err = DecodeExpr(p, data)
+	assert.NoError(t, err, "TestParserWithGlob failed")
+	value, err = expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithGlob failed")
+	assert.Equal(t, true, value, "TestParserWithGlob failed")
+
+	_, err = p.Parse([]byte(`{str:path} glob 1`), nil)
+	assert.Error(t, err, "TestParserWithGlob failed")
+
+	_, err = p.Parse([]byte(`{str:path} glob #/api/[a#`), nil)
+	assert.Error(t, err, "TestParserWithGlob failed")
+
+	// `#` is not the glob delimiter without WithGlobOp
+	p = NewParser(testVarFactory,
+		WithOp("===", testStrEqual),
+		WithVarType("str:", Str),
+		WithBudget(Budget{MaxRegexpLength: 1}))
+	expr, err = p.Parse([]byte(`{str:color} === #fff`), nil)
+	assert.NoError(t, err, "TestParserWithGlob failed")
+	value, err = expr.Exec(map[string]string{"color": "#fff"})
+	assert.NoError(t, err, "TestParserWithGlob failed")
+	assert.Equal(t, true, value, "TestParserWithGlob failed")
+
+	// the regexp length budget is not applied to the globs
+	p = NewParser(testVarFactory,
+		WithGlobOp("glob"),
+		WithVarType("str:", Str),
+		WithBudget(Budget{MaxRegexpLength: 1}))
+	expr, err = p.Parse([]byte(`{str:path} glob #/api/**#`), nil)
+	assert.NoError(t, err, "TestParserWithGlob failed")
+	value, err = expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithGlob failed")
+	assert.Equal(t, true, value, "TestParserWithGlob failed")
+}
+
+func TestValueByTypeWithGlob(t *testing.T) {
+	value, err := ValueByType(nil, Glob)
+	assert.NoError(t, err, "TestValueByTypeWithGlob failed")
+	assert.True(t, value.(*GlobPattern).MatchString("/any/path"), "TestValueByTypeWithGlob failed")
+
+	value, err = ValueByType([]byte("/a/*"), Glob)
+	assert.NoError(t, err, "TestValueByTypeWithGlob failed")
+	assert.True(t, value.(*GlobPattern).MatchString("/a/b"), "TestValueByTypeWithGlob failed")
+
+	assert.Equal(t, "glob", Glob.String(), "TestValueByTypeWithGlob failed")
+}
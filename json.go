@@ -0,0 +1,315 @@
+package expr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	kindNode   = "node"
+	kindVar    = "var"
+	kindStr    = "str"
+	kindNum    = "num"
+	kindRegexp = "regexp"
+	kindArray  = "array"
+	kindBool   = "bool"
+	kindGlob   = "glob"
+	kindCast   = "cast"
+	kindMacro  = "macro"
+)
+
+// exprDesc the description of a expr used by the json and binary encodings
+type exprDesc struct {
+	Kind  string      `json:"kind"`
+	Text  string      `json:"text,omitempty"`
+	Span  *Span       `json:"span,omitempty"`
+	Exprs []*exprDesc `json:"exprs,omitempty"`
+	Ops   []string    `json:"ops,omitempty"`
+	// OpSpans the spans of the ops, not encoded by the binary encoding
+	OpSpans []Span      `json:"op_spans,omitempty"`
+	Name    string      `json:"name,omitempty"`
+	Type    string      `json:"type,omitempty"`
+	Value   interface{} `json:"value,omitempty"`
+	Flags   string      `json:"flags,omitempty"`
+	// Comments the comments of the root node
+	Comments []Comment `json:"comments,omitempty"`
+}
+
+// MarshalExpr returns the json of the expr returned by Parser, the ops are
+// encoded as the symbols, the vars are encoded as the name and the var type.
+func MarshalExpr(expr Expr) ([]byte, error) {
+	value, err := describe(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(value)
+}
+
+// UnmarshalExpr returns the executable expr from the json returned by MarshalExpr,
+// the ops and the vars are created by the registry and the factory of the parser.
+func UnmarshalExpr(parser Parser, data []byte) (Expr, error) {
+	p, err := templateOf(parser)
+	if err != nil {
+		return nil, err
+	}
+
+	value := &exprDesc{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(value); err != nil {
+		return nil, err
+	}
+
+	expr, err := p.build(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.finish(expr)
+}
+
+func describe(expr Expr) (*exprDesc, error) {
+	value := &exprDesc{}
+	if s, ok := expr.(sourceExpr); ok {
+		value.Text = s.sourceText()
+	}
+
+	switch e := expr.(type) {
+	case *node:
+		value.Kind = kindNode
+		value.Span = &e.span
+		value.Comments = e.comments
+		for _, sub := range e.exprs {
+			v, err := describe(sub)
+			if err != nil {
+				return nil, err
+			}
+			value.Exprs = append(value.Exprs, v)
+		}
+		for _, op := range e.ops {
+			value.Ops = append(value.Ops, op.symbol)
+		}
+		value.OpSpans = append(value.OpSpans, e.opSpans...)
+	case *castExpr:
+		value.Kind = kindCast
+		value.Span = &e.span
+		value.Type = e.varType.String()
+		v, err := describe(e.expr)
+		if err != nil {
+			return nil, err
+		}
+		value.Exprs = append(value.Exprs, v)
+	case *macroExpr:
+		value.Kind = kindMacro
+		value.Span = &e.span
+		value.Name = e.name
+		v, err := describe(e.expr)
+		if err != nil {
+			return nil, err
+		}
+		value.Exprs = append(value.Exprs, v)
+	case *varExpr:
+		value.Kind = kindVar
+		value.Span = &e.span
+		value.Name = e.name
+		value.Type = e.varType.String()
+	case *constString:
+		value.Kind = kindStr
+		value.Value = e.value
+	case *constInt64:
+		value.Kind = kindNum
+		value.Value = e.value
+	case *constRegexp:
+		value.Kind = kindRegexp
+		value.Value = e.pattern
+		value.Flags = e.flags
+	case *constArray:
+		value.Kind = kindArray
+		value.Value = e.values
+	case *constGlob:
+		value.Kind = kindGlob
+		value.Value = e.value.String()
+	case *constValue:
+		switch v := e.value.(type) {
+		case bool:
+			value.Kind = kindBool
+			value.Value = v
+		default:
+			return nil, fmt.Errorf("const %T not support", e.value)
+		}
+	default:
+		return nil, fmt.Errorf("expr %T not support", expr)
+	}
+
+	return value, nil
+}
+
+func (p *parserTemplate) build(value *exprDesc) (Expr, error) {
+	var expr Expr
+	switch value.Kind {
+	case kindNode:
+		if len(value.Exprs) == 0 || len(value.Ops) != len(value.Exprs)-1 {
+			return nil, fmt.Errorf("node with %d exprs and %d ops", len(value.Exprs), len(value.Ops))
+		}
+
+		n := &node{comments: value.Comments}
+		if value.Span != nil {
+			n.span = *value.Span
+		}
+		for idx, v := range value.Exprs {
+			sub, err := p.build(v)
+			if err != nil {
+				return nil, err
+			}
+
+			if idx == 0 {
+				n.append(sub)
+				continue
+			}
+
+			op, ok := p.opsBySymbol[value.Ops[idx-1]]
+			if !ok {
+				return nil, fmt.Errorf("op <%s> not support", value.Ops[idx-1])
+			}
+
+			var span Span
+			if len(value.OpSpans) == len(value.Ops) {
+				span = value.OpSpans[idx-1]
+			}
+			n.appendWithOP(op, span, sub)
+		}
+		expr = n
+	case kindCast:
+		if len(value.Exprs) != 1 {
+			return nil, fmt.Errorf("cast with %d exprs", len(value.Exprs))
+		}
+
+		varType, err := ParseVarType(value.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		sub, err := p.build(value.Exprs[0])
+		if err != nil {
+			return nil, err
+		}
+
+		cast := &castExpr{varType: varType, expr: sub}
+		if value.Span != nil {
+			cast.span = *value.Span
+		}
+		expr = cast
+	case kindMacro:
+		if len(value.Exprs) != 1 {
+			return nil, fmt.Errorf("macro with %d exprs", len(value.Exprs))
+		}
+
+		sub, err := p.build(value.Exprs[0])
+		if err != nil {
+			return nil, err
+		}
+
+		// the macro expr is finished alone like the macro parsed by the parser
+		if sub, err = p.finish(sub); err != nil {
+			return nil, err
+		}
+		if n, ok := sub.(*node); ok {
+			n.budget = nil
+		}
+
+		m := &macroExpr{name: value.Name, expr: sub}
+		if value.Span != nil {
+			m.span = *value.Span
+		}
+		expr = m
+	case kindVar:
+		varType, err := ParseVarType(value.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		var span Span
+		if value.Span != nil {
+			span = *value.Span
+		}
+		return p.newVarExpr(value.Name, varType, value.Text, span)
+	case kindStr:
+		v, ok := value.Value.(string)
+		if !ok && value.Value != nil {
+			return nil, fmt.Errorf("%+v is not string", value.Value)
+		}
+		expr = &constString{value: v}
+	case kindNum:
+		var v int64
+		switch n := value.Value.(type) {
+		case nil:
+		case int64:
+			v = n
+		case json.Number:
+			var err error
+			if v, err = n.Int64(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("%+v is not int64", value.Value)
+		}
+		expr = &constInt64{value: v}
+	case kindRegexp:
+		v, ok := value.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%+v is not string", value.Value)
+		}
+		pattern, err := newRegexpExpr(v, value.Flags, p.opts.matcher)
+		if err != nil {
+			return nil, err
+		}
+		expr = pattern
+	case kindArray:
+		arr := []string{}
+		switch values := value.Value.(type) {
+		case nil:
+		case []string:
+			arr = values
+		case []interface{}:
+			for _, v := range values {
+				s, ok := v.(string)
+				if !ok {
+					return nil, fmt.Errorf("%+v is not string", v)
+				}
+				arr = append(arr, s)
+			}
+		default:
+			return nil, fmt.Errorf("%+v is not array", value.Value)
+		}
+		expr = newArrayExpr(arr, p.opts.arraySet)
+	case kindGlob:
+		v, ok := value.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%+v is not string", value.Value)
+		}
+		pattern, err := CompileGlob(v)
+		if err != nil {
+			return nil, err
+		}
+		expr = &constGlob{value: pattern}
+	case kindBool:
+		v, ok := value.Value.(bool)
+		if !ok && value.Value != nil {
+			return nil, fmt.Errorf("%+v is not bool", value.Value)
+		}
+		expr = &constValue{value: v}
+	default:
+		return nil, fmt.Errorf("expr kind %s not support", value.Kind)
+	}
+
+	if isConst(expr) {
+		if err := p.checkConst(expr); err != nil {
+			return nil, err
+		}
+	}
+
+	expr.(sourceExpr).setSourceText(value.Text)
+	return expr, nil
+}
@@ -0,0 +1,63 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalExpr(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("+", testAdd),
+		WithOp("==", testEqual),
+		WithOp("&&", testAndLogic),
+		WithOp("in", testStrIn),
+		WithOp("~", testMatch),
+		WithVarType("num:", Num),
+		WithVarType("str:", Str),
+		WithConst("ENABLED", true))
+
+	ctx := make(map[string]string)
+	ctx["a"] = "1"
+	ctx["b"] = "abc"
+
+	expr, err := p.Parse([]byte("(({num:a}+0==1)&&({str:b} in [abc,d]))&&({str:b}~|^a.c$|)&&{ENABLED}"), nil)
+	assert.NoError(t, err, "TestMarshalExpr failed")
+
+	data, err := MarshalExpr(expr)
+	assert.NoError(t, err, "TestMarshalExpr failed")
+
+	value, err := UnmarshalExpr(p, data)
+	assert.NoError(t, err, "TestMarshalExpr failed")
+	result, err := value.Exec(ctx)
+	assert.NoError(t, err, "TestMarshalExpr failed")
+	assert.Equal(t, true, result, "TestMarshalExpr failed")
+	assert.Equal(t, Variables(expr), Variables(value), "TestMarshalExpr failed")
+
+	again, err := MarshalExpr(value)
+	assert.NoError(t, err, "TestMarshalExpr failed")
+	assert.Equal(t, string(data), string(again), "TestMarshalExpr failed")
+
+	_, err = UnmarshalExpr(NewParser(testVarFactory, WithOp("+", testAdd)), data)
+	assert.Error(t, err, "TestMarshalExpr failed")
+}
+
+func TestUnmarshalExprWithOpSpans(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("+", testAdd),
+		WithOpSignature("+", Signature{Left: Num, Right: Num, Result: Num}),
+		WithVarType("num:", Num),
+		WithVarType("str:", Str))
+
+	expr, err := p.Parse([]byte("{num:a} + {str:b}"), nil)
+	assert.NoError(t, err, "TestUnmarshalExprWithOpSpans failed")
+
+	data, err := MarshalExpr(expr)
+	assert.NoError(t, err, "TestUnmarshalExprWithOpSpans failed")
+	value, err := UnmarshalExpr(p, data)
+	assert.NoError(t, err, "TestUnmarshalExprWithOpSpans failed")
+
+	_, err = Check(value)
+	assert.Error(t, err, "TestUnmarshalExprWithOpSpans failed")
+	assert.Equal(t, Span{Start: 8, End: 9}, err.(*TypeError).Span, "TestUnmarshalExprWithOpSpans failed")
+}
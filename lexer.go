@@ -1,5 +1,10 @@
 package expr
 
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
 var (
 	// EOI end of input
 	EOI byte = 0x1A
@@ -7,6 +12,35 @@ var (
 	TokenEOI = 0
 )
 
+const (
+	// tokenLeftParen (
+	tokenLeftParen = 1
+	// tokenRightParen )
+	tokenRightParen = 2
+	// tokenVarStart {
+	tokenVarStart = 3
+	// tokenVarEnd }
+	tokenVarEnd = 4
+	// tokenLiteral the quotation of string literals
+	tokenLiteral = 5
+	// tokenRegexp the delimiter of regexp literals
+	tokenRegexp = 6
+	// tokenArrayStart [
+	tokenArrayStart = 7
+	// tokenArrayEnd ]
+	tokenArrayEnd = 8
+	// tokenSingleLiteral the single quotation of string literals
+	tokenSingleLiteral = 9
+	// tokenRawLiteral the backtick of raw string literals
+	tokenRawLiteral = 10
+	// tokenGlob the delimiter of glob literals
+	tokenGlob = 11
+	// tokenCustom the ops and the var types use the tokens after tokenCustom
+	tokenCustom = 100
+	// tokenUnknown no token
+	tokenUnknown = 10000
+)
+
 // Lexer lexer to scan the input text
 type Lexer interface {
 	// AddSymbol add a symbol
@@ -19,7 +53,7 @@ type Lexer interface {
 	Current() byte
 	/// Token returns the current token
 	Token() int
-	// TokenIndex returns the index of the input chars
+	// TokenIndex returns the byte offset of the last byte of the current token
 	TokenIndex() int
 	// TokenSymbol returns token symbol
 	TokenSymbol(int) string
@@ -28,3 +62,69 @@ type Lexer interface {
 	// SkipString move the sp to the current token
 	SkipString()
 }
+
+// RuneLexer the Lexer scans the input by the UTF-8 runes, the Lexer returned by NewScanner
+// implements it
+type RuneLexer interface {
+	Lexer
+	// NextRune returns the next rune
+	NextRune() rune
+	// CurrentRune returns the current rune
+	CurrentRune() rune
+}
+
+// CommentLexer the Lexer skips the `//` line comments and the `/* */` block comments
+// outside the literals, the Lexer returned by NewScanner implements it
+type CommentLexer interface {
+	Lexer
+	// ScanComments enable the comments, the comments are not recognized by default
+	ScanComments()
+	// Comments returns the comments scanned
+	Comments() []Comment
+	// Err returns the error of the unclosed block comment
+	Err() error
+}
+
+// Comment a `//` line comment or a `/* */` block comment, the text includes the delimiters
+type Comment struct {
+	Text string `json:"text"`
+	Span Span   `json:"span"`
+}
+
+// Position the position in the input, the line and the column are 1-based
+// and the column is counted in runes
+type Position struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// location returns the byte offset followed by the line and the column used by the errors
+func (p Position) location() string {
+	return fmt.Sprintf("%d (%s)", p.Offset, p)
+}
+
+// PositionOf returns the position of the byte offset in the input
+func PositionOf(input []byte, offset int) Position {
+	if offset > len(input) {
+		offset = len(input)
+	}
+
+	pos := Position{Offset: offset, Line: 1, Column: 1}
+	for i := 0; i < offset; {
+		r, size := utf8.DecodeRune(input[i:])
+		if r == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+		i += size
+	}
+
+	return pos
+}
@@ -0,0 +1,163 @@
+package expr
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// boolLiterals the bool constants with WithTypeCheck, they are the strings without it
+	boolLiterals = map[string]bool{
+		"true":  true,
+		"false": false,
+	}
+)
+
+// isQuoted returns true if the value starts with the open and ends with the close
+func isQuoted(value []byte, open, close byte) bool {
+	return len(value) >= 2 && value[0] == open && value[len(value)-1] == close
+}
+
+// unquote returns the value of the string literal body. It supports the escapes
+// `\a`, `\b`, `\f`, `\n`, `\r`, `\t`, `\v`, `\xNN`, `\uXXXX`, `\UXXXXXXXX`, and
+// the escaped quotations, slashes, `|`, `[`, `]` and `,`.
+func unquote(body []byte) (string, error) {
+	if !strings.ContainsRune(string(body), slash) {
+		return string(body), nil
+	}
+
+	var buf strings.Builder
+	for i := 0; i < len(body); {
+		c := body[i]
+		if c != slash {
+			buf.WriteByte(c)
+			i++
+			continue
+		}
+
+		if i+1 >= len(body) {
+			return "", fmt.Errorf("invalid escape at the end of %q", body)
+		}
+
+		c = body[i+1]
+		i += 2
+		switch c {
+		case 'a':
+			buf.WriteByte('\a')
+		case 'b':
+			buf.WriteByte('\b')
+		case 'f':
+			buf.WriteByte('\f')
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 't':
+			buf.WriteByte('\t')
+		case 'v':
+			buf.WriteByte('\v')
+		case slash, quotation, singleQuotation, backtick, vertical, arrayLeft, arrayRight, comma, '/':
+			buf.WriteByte(c)
+		case 'x', 'u', 'U':
+			n := 2
+			if c == 'u' {
+				n = 4
+			} else if c == 'U' {
+				n = 8
+			}
+
+			if i+n > len(body) {
+				return "", fmt.Errorf("invalid escape \\%c in %q", c, body)
+			}
+			value, err := strconv.ParseUint(string(body[i:i+n]), 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("invalid escape \\%c%s in %q", c, body[i:i+n], body)
+			}
+			i += n
+
+			if c == 'x' {
+				buf.WriteByte(byte(value))
+			} else if r := rune(value); utf8.ValidRune(r) {
+				buf.WriteRune(r)
+			} else {
+				return "", fmt.Errorf("invalid unicode code point %U in %q", r, body)
+			}
+		default:
+			return "", fmt.Errorf("invalid escape \\%c in %q", c, body)
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// splitRegexp returns the pattern and the flags of the regexp literal `|pattern|flags`
+// with the delimiter, the flags are the regexp flags, so that the text like `/api/users`
+// is not a regexp literal if the delimiter is `/`
+func splitRegexp(value []byte, delimiter byte) (string, string, bool) {
+	if len(value) < 2 || value[0] != delimiter {
+		return "", "", false
+	}
+
+	end := bytes.LastIndexByte(value, delimiter)
+	if end == 0 {
+		return "", "", false
+	}
+
+	for _, c := range value[end+1:] {
+		if !isFlag(c) {
+			return "", "", false
+		}
+	}
+
+	return unescapeRegexp(value[1:end], delimiter), string(value[end+1:]), true
+}
+
+// unescapeRegexp returns the pattern of the regexp literal body, only the escaped
+// delimiter is unescaped, the other escapes are kept for the regexp
+func unescapeRegexp(body []byte, delimiter byte) string {
+	var buf strings.Builder
+	for i := 0; i < len(body); i++ {
+		if body[i] == slash && i+1 < len(body) {
+			if body[i+1] != delimiter {
+				buf.WriteByte(slash)
+			}
+			i++
+		}
+		buf.WriteByte(body[i])
+	}
+
+	return buf.String()
+}
+
+// splitArray returns the values of the array literal body split by the unescaped
+// commas, the values are unquoted
+func splitArray(body []byte) ([]string, error) {
+	var values []string
+	start := 0
+	for i := 0; i < len(body); i++ {
+		if body[i] == slash {
+			i++
+		} else if body[i] == comma {
+			value, err := unquote(body[start:i])
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			start = i + 1
+		}
+	}
+
+	value, err := unquote(body[start:])
+	if err != nil {
+		return nil, err
+	}
+	return append(values, value), nil
+}
+
+// isFlag returns true if the char is a regexp flag `i`, `m`, `s` or `U`
+func isFlag(c byte) bool {
+	return c == 'i' || c == 'm' || c == 's' || c == 'U'
+}
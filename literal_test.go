@@ -0,0 +1,39 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnquote(t *testing.T) {
+	value, err := unquote([]byte(`a\"b\'c\\d`))
+	assert.NoError(t, err, "TestUnquote failed")
+	assert.Equal(t, `a"b'c\d`, value, "TestUnquote failed")
+
+	value, err = unquote([]byte(`\n\t\x41中\U0001F600\|\[\]\,`))
+	assert.NoError(t, err, "TestUnquote failed")
+	assert.Equal(t, "\n\tA中\U0001F600|[],", value, "TestUnquote failed")
+
+	value, err = unquote([]byte("\x00\x01"))
+	assert.NoError(t, err, "TestUnquote failed")
+	assert.Equal(t, "\x00\x01", value, "TestUnquote failed")
+
+	for _, body := range []string{`\`, `\q`, `\x4`, `\xzz`, `\uD800`} {
+		_, err = unquote([]byte(body))
+		assert.Error(t, err, "TestUnquote failed")
+	}
+}
+
+func TestUnescapeRegexp(t *testing.T) {
+	assert.Equal(t, `^[|]+\d\\$`, unescapeRegexp([]byte(`^[\|]+\d\\$`), '|'), "TestUnescapeRegexp failed")
+}
+
+func TestSplitArray(t *testing.T) {
+	values, err := splitArray([]byte(`\\1,\|,a\,b,\n`))
+	assert.NoError(t, err, "TestSplitArray failed")
+	assert.Equal(t, []string{`\1`, "|", "a,b", "\n"}, values, "TestSplitArray failed")
+
+	_, err = splitArray([]byte(`1,\`))
+	assert.Error(t, err, "TestSplitArray failed")
+}
@@ -0,0 +1,64 @@
+package expr
+
+import (
+	"container/list"
+)
+
+// lru a bounded lru cache, not safe for concurrent use
+type lru struct {
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value interface{}
+}
+
+func newLRU(size int) *lru {
+	return &lru{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *lru) get(key string) (interface{}, bool) {
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*lruEntry).value, true
+	}
+
+	return nil, false
+}
+
+// add adds the value, returns the number of the evicted values
+func (c *lru) add(key string, value interface{}) int {
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*lruEntry).value = value
+		return 0
+	}
+
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value})
+	return c.resize(c.size)
+}
+
+// resize evicts the oldest values until the cache has at most size values
+func (c *lru) resize(size int) int {
+	c.size = size
+
+	evicted := 0
+	for c.ll.Len() > c.size {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.items, e.Value.(*lruEntry).key)
+		evicted++
+	}
+	return evicted
+}
+
+func (c *lru) len() int {
+	return c.ll.Len()
+}
@@ -0,0 +1,117 @@
+package expr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	macroPrefix = '@'
+)
+
+// macro the pre-parsed macro added by WithMacro
+type macro struct {
+	expr Expr
+	err  error
+}
+
+// macroExpr the expr expanded from the macro, the expr of the macro is shared by
+// all the exprs use the macro
+type macroExpr struct {
+	source
+	name string
+	span Span
+	expr Expr
+}
+
+func (expr *macroExpr) Exec(ctx interface{}) (interface{}, error) {
+	return expr.expr.Exec(ctx)
+}
+
+func (expr *macroExpr) ExecContext(ctx context.Context, env interface{}) (interface{}, error) {
+	return ExecContext(ctx, expr.expr, env)
+}
+
+// parseMacros parses all the macros, the macros used by the other macros are parsed first
+func (p *parserTemplate) parseMacros() {
+	for _, name := range sortedKeys(p.opts.macros) {
+		if isIdentifier([]byte(name)) {
+			p.parseMacro(name, nil)
+		}
+	}
+}
+
+// parseMacro returns the parsed macro, the stack is the names of the macros being parsed
+func (p *parserTemplate) parseMacro(name string, stack []string) *macro {
+	if m, ok := p.macros[name]; ok {
+		return m
+	}
+
+	for idx, n := range stack {
+		if n == name {
+			cycle := append(stack[idx:], name)
+			return &macro{
+				err: fmt.Errorf("macro cycle @%s", strings.Join(cycle, " -> @")),
+			}
+		}
+	}
+
+	parser := p.newParser([]byte(p.opts.macros[name]))
+	parser.macroStack = append(stack[:len(stack):len(stack)], name)
+	// the parsed expr is finished by the parser
+	expr, err := parser.parse(nil)
+	if err != nil {
+		// the position in the macro source
+		err = fmt.Errorf("@%s before %s: %w", name, parser.position().location(), err)
+	}
+
+	m := &macro{expr: expr, err: err}
+	if n, ok := expr.(*node); ok && err == nil {
+		// the budget is checked by the expr uses the macro
+		n.budget = nil
+	}
+	if err != nil {
+		m.expr = nil
+	}
+
+	p.macros[name] = m
+	return m
+}
+
+func (p *parser) doMacro(name string) error {
+	end := p.lexer.TokenIndex() + 1
+	span := p.span(end-len(p.lexer.TokenSymbol(p.lexer.Token())), end)
+	pos := PositionOf(p.input, span.Start)
+
+	m := p.template.parseMacro(name, p.macroStack)
+	if m.err != nil {
+		return fmt.Errorf("expand @%s at %s: %w", name, pos, m.err)
+	}
+
+	expr := &macroExpr{
+		name: name,
+		span: span,
+		expr: m.expr,
+	}
+	expr.setSourceText(string(p.input[span.Start:span.End]))
+
+	if _, ok := p.template.ops[p.prevToken]; ok {
+		p.stack.current().appendWithOP(p.template.ops[p.prevToken], p.opSpan, expr)
+	} else if p.prevToken == tokenUnknown || p.prevToken == tokenLeftParen {
+		p.stack.current().append(expr)
+	} else {
+		return p.unexpected()
+	}
+
+	if p.cb != nil {
+		walk(m.expr, func(e Expr) {
+			if v, ok := e.(*varExpr); ok {
+				p.cb(v.expr)
+			}
+		})
+	}
+
+	p.lexer.SkipString()
+	return nil
+}
@@ -0,0 +1,164 @@
+package expr
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestMacroParser(opts ...Option) Parser {
+	opts = append([]Option{
+		WithOp("==", testEqual),
+		WithOp("===", testStrEqual),
+		WithOp("&&", testAndLogic),
+		WithVarType("num:", Num),
+		WithVarType("str:", Str),
+		WithMacro("adult", "{num:age} == 18"),
+		WithMacro("domestic", `{str:country} === "cn"`),
+		WithMacro("adult_domestic", "@adult && @domestic"),
+	}, opts...)
+	return NewParser(testVarFactory, opts...)
+}
+
+func TestParserWithMacro(t *testing.T) {
+	p := newTestMacroParser()
+
+	ctx := make(map[string]string)
+	ctx["age"] = "18"
+	ctx["country"] = "cn"
+	ctx["name"] = "a"
+
+	var vars []Expr
+	expr, err := p.Parse([]byte(`({str:name} === "a") && @adult_domestic`), func(e Expr) {
+		vars = append(vars, e)
+	})
+	assert.NoError(t, err, "TestParserWithMacro failed")
+	assert.Equal(t, 3, len(vars), "TestParserWithMacro failed")
+
+	value, err := expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithMacro failed")
+	assert.Equal(t, true, value, "TestParserWithMacro failed")
+
+	ctx["country"] = "us"
+	value, err = expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithMacro failed")
+	assert.Equal(t, false, value, "TestParserWithMacro failed")
+
+	refs := Variables(expr)
+	assert.Equal(t, 3, len(refs), "TestParserWithMacro failed")
+	assert.Equal(t, "age", refs[1].Name, "TestParserWithMacro failed")
+	assert.Equal(t, "adult_domestic", refs[1].Macro, "TestParserWithMacro failed")
+	assert.Equal(t, Span{Start: 24, End: 39}, refs[1].Span, "TestParserWithMacro failed")
+	assert.Equal(t, "", refs[0].Macro, "TestParserWithMacro failed")
+
+	tokens, err := Tokenize(p, []byte("@adult && @domestic"))
+	assert.NoError(t, err, "TestParserWithMacro failed")
+	assert.Equal(t, KindMacro, tokens[0].Kind, "TestParserWithMacro failed")
+	assert.Equal(t, "@adult", tokens[0].Text, "TestParserWithMacro failed")
+
+	_, err = p.Parse([]byte("@adult && @child"), nil)
+	assert.Error(t, err, "TestParserWithMacro failed")
+	assert.Contains(t, err.Error(), "macro @child not defined", "TestParserWithMacro failed")
+}
+
+func TestParserWithMacroErrors(t *testing.T) {
+	p := newTestMacroParser(
+		WithMacro("a", "@b && @adult"),
+		WithMacro("b", "@a"),
+		WithMacro("broken", "{num:age} == (1"))
+
+	_, err := p.Parse([]byte("@adult && @a"), nil)
+	assert.Error(t, err, "TestParserWithMacroErrors failed")
+	assert.Contains(t, err.Error(), "expand @a at 1:11", "TestParserWithMacroErrors failed")
+	assert.Contains(t, err.Error(), "macro cycle @a -> @b -> @a", "TestParserWithMacroErrors failed")
+
+	_, err = p.Parse([]byte("@broken"), nil)
+	assert.Error(t, err, "TestParserWithMacroErrors failed")
+	assert.Contains(t, err.Error(), "expand @broken at 1:1", "TestParserWithMacroErrors failed")
+
+	_, macroErr := newTestMacroParser().Parse([]byte("{num:age} == (1"), nil)
+	assert.Error(t, macroErr, "TestParserWithMacroErrors failed")
+	assert.Contains(t, err.Error(), macroErr.Error(), "TestParserWithMacroErrors failed")
+
+	// the errors have the position in the macro source
+	p = newTestMacroParser(
+		WithOp("~", testMatch),
+		WithMacro("bad", "{num:age} ==\n  {num:b"),
+		WithMacro("pattern", "{str:name} ~ |a(|"))
+	_, err = p.Parse([]byte("@adult &&\n @bad"), nil)
+	assert.Error(t, err, "TestParserWithMacroErrors failed")
+	assert.Contains(t, err.Error(), "expand @bad at 2:2: @bad before ", "TestParserWithMacroErrors failed")
+	assert.Contains(t, err.Error(), "(2:", "TestParserWithMacroErrors failed")
+	assert.Contains(t, err.Error(), "missing }", "TestParserWithMacroErrors failed")
+
+	_, err = p.Parse([]byte("@pattern"), nil)
+	assert.Error(t, err, "TestParserWithMacroErrors failed")
+	assert.Contains(t, err.Error(), "expand @pattern at 1:1: @pattern before 16 (1:17): error parsing regexp", "TestParserWithMacroErrors failed")
+
+	for _, name := range []string{"", "a b"} {
+		_, err = newTestMacroParser(WithMacro(name, "1")).Parse([]byte("@adult"), nil)
+		assert.Error(t, err, "TestParserWithMacroErrors failed")
+		assert.Contains(t, err.Error(), "invalid macro name", "TestParserWithMacroErrors failed")
+	}
+
+	// the macros are not expanded in the literals
+	expr, err := p.Parse([]byte(`{str:country} === "@adult"`), nil)
+	assert.NoError(t, err, "TestParserWithMacroErrors failed")
+	value, err := expr.Exec(map[string]string{"country": "@adult"})
+	assert.NoError(t, err, "TestParserWithMacroErrors failed")
+	assert.Equal(t, true, value, "TestParserWithMacroErrors failed")
+}
+
+func TestEncodeExprWithMacro(t *testing.T) {
+	p := newTestMacroParser()
+
+	ctx := make(map[string]string)
+	ctx["age"] = "18"
+	ctx["country"] = "cn"
+
+	expr, err := p.Parse([]byte("@adult_domestic"), nil)
+	assert.NoError(t, err, "TestEncodeExprWithMacro failed")
+
+	data, err := EncodeExpr(expr)
+	assert.NoError(t, err, "TestEncodeExprWithMacro failed")
+	value, err := DecodeExpr(p, data)
+	assert.NoError(t, err, "TestEncodeExprWithMacro failed")
+	result, err := value.Exec(ctx)
+	assert.NoError(t, err, "TestEncodeExprWithMacro failed")
+	assert.Equal(t, true, result, "TestEncodeExprWithMacro failed")
+	assert.Equal(t, Variables(expr), Variables(value), "TestEncodeExprWithMacro failed")
+
+	data, err = MarshalExpr(expr)
+	assert.NoError(t, err, "TestEncodeExprWithMacro failed")
+	value, err = UnmarshalExpr(p, data)
+	assert.NoError(t, err, "TestEncodeExprWithMacro failed")
+	result, err = value.Exec(ctx)
+	assert.NoError(t, err, "TestEncodeExprWithMacro failed")
+	assert.Equal(t, true, result, "TestEncodeExprWithMacro failed")
+
+	trace, err := Explain(expr, ctx)
+	assert.NoError(t, err, "TestEncodeExprWithMacro failed")
+	assert.Equal(t, "@adult_domestic", trace.Text, "TestEncodeExprWithMacro failed")
+	assert.Equal(t, true, trace.Value, "TestEncodeExprWithMacro failed")
+	assert.Equal(t, 1, len(trace.Children), "TestEncodeExprWithMacro failed")
+}
+
+func TestParserWithMacroConcurrent(t *testing.T) {
+	p := newTestMacroParser()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			expr, err := p.Parse([]byte("@adult_domestic && @adult"), nil)
+			assert.NoError(t, err, "TestParserWithMacroConcurrent failed")
+
+			value, err := expr.Exec(map[string]string{"age": "18", "country": "cn"})
+			assert.NoError(t, err, "TestParserWithMacroConcurrent failed")
+			assert.Equal(t, true, value, "TestParserWithMacroConcurrent failed")
+		}()
+	}
+	wg.Wait()
+}
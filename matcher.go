@@ -0,0 +1,103 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Matcher matches the strings, it's the value of the regexp literals and *regexp.Regexp is a Matcher
+type Matcher interface {
+	MatchString(string) bool
+	String() string
+}
+
+// MatcherFactory returns the matcher of the regexp literal pattern and the flags after the
+// closing delimiter
+type MatcherFactory func(pattern string, flags string) (Matcher, error)
+
+// CompileRegexp returns the *regexp.Regexp of the pattern, the flags are
+// `i` (case-insensitive), `m` (multi-line), `s` (let . match \n) and `U`
+// (ungreedy). The compiled regexps are cached, see SetRegexpCacheSize
+func CompileRegexp(pattern string, flags string) (Matcher, error) {
+	for _, flag := range flags {
+		switch flag {
+		case 'i', 'm', 's', 'U':
+		default:
+			return nil, fmt.Errorf("regexp flag <%c> not support", flag)
+		}
+	}
+
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+
+	r, err := regexps.compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// CompileWildcard returns the matcher of the wildcard pattern, `*` matches any
+// sequence of runes and `?` matches any single rune, the whole string must be
+// matched. The flag is `i` (case-insensitive).
+func CompileWildcard(pattern string, flags string) (Matcher, error) {
+	m := &wildcardMatcher{pattern: pattern}
+	for _, flag := range flags {
+		switch flag {
+		case 'i':
+			m.fold = true
+		default:
+			return nil, fmt.Errorf("wildcard flag <%c> not support", flag)
+		}
+	}
+
+	if m.fold {
+		m.runes = []rune(strings.ToLower(pattern))
+	} else {
+		m.runes = []rune(pattern)
+	}
+	return m, nil
+}
+
+type wildcardMatcher struct {
+	pattern string
+	runes   []rune
+	fold    bool
+}
+
+func (m *wildcardMatcher) String() string {
+	return m.pattern
+}
+
+func (m *wildcardMatcher) MatchString(value string) bool {
+	if m.fold {
+		value = strings.ToLower(value)
+	}
+
+	s := []rune(value)
+	p := m.runes
+	si, pi := 0, 0
+	star, mark := -1, 0
+	for si < len(s) {
+		if pi < len(p) && (p[pi] == '?' || p[pi] == s[si]) {
+			si++
+			pi++
+		} else if pi < len(p) && p[pi] == '*' {
+			star, mark = pi, si
+			pi++
+		} else if star >= 0 {
+			pi = star + 1
+			mark++
+			si = mark
+		} else {
+			return false
+		}
+	}
+
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
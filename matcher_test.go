@@ -0,0 +1,39 @@
+package expr
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileRegexp(t *testing.T) {
+	m, err := CompileRegexp("^a.b$", "is")
+	assert.NoError(t, err, "TestCompileRegexp failed")
+	assert.IsType(t, &regexp.Regexp{}, m, "TestCompileRegexp failed")
+	assert.True(t, m.MatchString("A\nB"), "TestCompileRegexp failed")
+
+	m, err = CompileRegexp("^a$", "m")
+	assert.NoError(t, err, "TestCompileRegexp failed")
+	assert.True(t, m.MatchString("b\na"), "TestCompileRegexp failed")
+
+	_, err = CompileRegexp("a", "g")
+	assert.Error(t, err, "TestCompileRegexp failed")
+}
+
+func TestCompileWildcard(t *testing.T) {
+	m, err := CompileWildcard("a*b?c*", "")
+	assert.NoError(t, err, "TestCompileWildcard failed")
+	assert.Equal(t, "a*b?c*", m.String(), "TestCompileWildcard failed")
+	assert.True(t, m.MatchString("abxc"), "TestCompileWildcard failed")
+	assert.True(t, m.MatchString("a12b中c34"), "TestCompileWildcard failed")
+	assert.False(t, m.MatchString("abc"), "TestCompileWildcard failed")
+	assert.False(t, m.MatchString("Abxc"), "TestCompileWildcard failed")
+
+	m, err = CompileWildcard("a*", "i")
+	assert.NoError(t, err, "TestCompileWildcard failed")
+	assert.True(t, m.MatchString("ABC"), "TestCompileWildcard failed")
+
+	_, err = CompileWildcard("a", "m")
+	assert.Error(t, err, "TestCompileWildcard failed")
+}
@@ -0,0 +1,101 @@
+package expr
+
+import (
+	"fmt"
+)
+
+type logicKind int
+
+const (
+	logicAnd logicKind = iota
+	logicOr
+)
+
+func int64CalcFunc(fn func(int64, int64) int64) CalcFunc {
+	return func(left interface{}, right Expr, ctx interface{}) (interface{}, error) {
+		a, b, err := int64Operands(left, right, ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return fn(a, b), nil
+	}
+}
+
+func int64CmpCalcFunc(fn func(int64, int64) bool) CalcFunc {
+	return func(left interface{}, right Expr, ctx interface{}) (interface{}, error) {
+		a, b, err := int64Operands(left, right, ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return fn(a, b), nil
+	}
+}
+
+func strCmpCalcFunc(fn func(string, string) bool) CalcFunc {
+	return func(left interface{}, right Expr, ctx interface{}) (interface{}, error) {
+		a, ok := left.(string)
+		if !ok {
+			return nil, fmt.Errorf("%+v is not string", left)
+		}
+
+		value, err := right.Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		b, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%+v is not string", value)
+		}
+
+		return fn(a, b), nil
+	}
+}
+
+// logicCalcFunc returns the short-circuit logical op, the right expr is not
+// executed if the left value equals to the stop value
+func logicCalcFunc(stop bool) CalcFunc {
+	return func(left interface{}, right Expr, ctx interface{}) (interface{}, error) {
+		a, ok := left.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%+v is not bool", left)
+		}
+
+		if a == stop {
+			return stop, nil
+		}
+
+		value, err := right.Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%+v is not bool", value)
+		}
+
+		return b, nil
+	}
+}
+
+func int64Operands(left interface{}, right Expr, ctx interface{}) (int64, int64, error) {
+	a, ok := left.(int64)
+	if !ok {
+		return 0, 0, fmt.Errorf("%+v is not int64", left)
+	}
+
+	value, err := right.Exec(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	b, ok := value.(int64)
+	if !ok {
+		return 0, 0, fmt.Errorf("%+v is not int64", value)
+	}
+
+	return a, b, nil
+}
@@ -0,0 +1,147 @@
+package expr
+
+import (
+	"context"
+)
+
+// OpAttr the attribute of a op, the ops are lazy, impure and not commutative by default
+type OpAttr int
+
+const (
+	// Eager the right operand is executed by the engine before calling the op, the op
+	// gets the right value as a constant expr
+	Eager OpAttr = 1 << iota
+	// Pure the result only depends on the operands, the ops with constant operands
+	// are folded at parse time
+	Pure
+	// Commutative the operands can be swapped, the cheaper operand is moved to the
+	// left at parse time, e.g. to short-circuit before executing a group
+	Commutative
+)
+
+// Has returns true if the attr has all the attrs of the value
+func (a OpAttr) Has(value OpAttr) bool {
+	return a&value == value
+}
+
+func eagerCalcFunc(fn CalcFunc) CalcFunc {
+	return func(left interface{}, right Expr, ctx interface{}) (interface{}, error) {
+		value, err := right.Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return fn(left, newValueExpr(value), ctx)
+	}
+}
+
+func eagerContextCalcFunc(fn ContextCalcFunc) ContextCalcFunc {
+	return func(ctx context.Context, left interface{}, right Expr, env interface{}) (interface{}, error) {
+		value, err := right.Exec(env)
+		if err != nil {
+			return nil, err
+		}
+
+		return fn(ctx, left, newValueExpr(value), env)
+	}
+}
+
+// calcFunc returns the fn executes the right operand first if the op is eager
+func (op *operator) calcFunc(fn CalcFunc) CalcFunc {
+	if fn == nil || !op.attrs.Has(Eager) {
+		return fn
+	}
+
+	return eagerCalcFunc(fn)
+}
+
+// reorder moves the cheaper operand of the commutative ops to the left
+func reorder(expr Expr) {
+	if c, ok := expr.(*castExpr); ok {
+		reorder(c.expr)
+		return
+	}
+
+	n, ok := expr.(*node)
+	if !ok {
+		return
+	}
+
+	for _, sub := range n.exprs {
+		reorder(sub)
+	}
+
+	if len(n.ops) > 0 && n.ops[0].attrs.Has(Commutative) &&
+		cost(n.exprs[1]) < cost(n.exprs[0]) {
+		n.exprs[0], n.exprs[1] = n.exprs[1], n.exprs[0]
+	}
+}
+
+func cost(expr Expr) int {
+	switch e := expr.(type) {
+	case *node:
+		if len(e.ops) == 0 {
+			return cost(e.exprs[0])
+		}
+		return 2
+	case *castExpr:
+		return cost(e.expr)
+	case *macroExpr:
+		return 2
+	case *varExpr:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// fold folds the pure ops with constant operands in the node and the sub nodes
+func fold(n *node) {
+	for idx, sub := range n.exprs {
+		n.exprs[idx] = foldGroup(sub)
+	}
+
+	for len(n.ops) > 0 && n.ops[0].attrs.Has(Pure) &&
+		isConst(n.exprs[0]) && isConst(n.exprs[1]) {
+		left, _ := n.exprs[0].Exec(nil)
+		value, err := n.fns[0](left, n.exprs[1], nil)
+		if err != nil {
+			// keep the error to be returned at runtime
+			return
+		}
+
+		expr := newValueExpr(value)
+		expr.(sourceExpr).setSourceText(n.exprs[0].(sourceExpr).sourceText() + " " +
+			n.ops[0].symbol + " " + n.exprs[1].(sourceExpr).sourceText())
+		n.exprs = append([]Expr{expr}, n.exprs[2:]...)
+		n.ops = n.ops[1:]
+		n.opSpans = n.opSpans[1:]
+		n.fns = n.fns[1:]
+	}
+}
+
+// foldGroup folds the group node or the group node in the cast, returns the constant
+// if the group is folded to a constant
+func foldGroup(expr Expr) Expr {
+	switch e := expr.(type) {
+	case *castExpr:
+		e.expr = foldGroup(e.expr)
+	case *node:
+		fold(e)
+		if len(e.ops) == 0 && isConst(e.exprs[0]) {
+			e.exprs[0].(sourceExpr).setSourceText(e.sourceText())
+			return e.exprs[0]
+		}
+	}
+
+	return expr
+}
+
+func isConst(expr Expr) bool {
+	switch expr.(type) {
+	case *constString, *constInt64, *constRegexp, *constArray, *constGlob, *constValue:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,129 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEagerOp(t *testing.T) {
+	var consts int
+	eq := func(left interface{}, right Expr, ctx interface{}) (interface{}, error) {
+		if isConst(right) {
+			consts++
+		}
+		return testEqual(left, right, ctx)
+	}
+
+	p := NewParser(testVarFactory,
+		WithOp("==", eq, Eager),
+		WithOp("+", testAdd),
+		WithVarType("num:", Num))
+
+	ctx := make(map[string]string)
+	ctx["a"] = "1"
+
+	expr, err := p.Parse([]byte("3 == ({num:a} + 2)"), nil)
+	assert.NoError(t, err, "TestEagerOp failed")
+	value, err := expr.Exec(ctx)
+	assert.NoError(t, err, "TestEagerOp failed")
+	assert.Equal(t, true, value, "TestEagerOp failed")
+	assert.Equal(t, 1, consts, "TestEagerOp failed")
+
+	prog, err := Compile(expr)
+	assert.NoError(t, err, "TestEagerOp failed")
+	value, err = prog.Exec(ctx)
+	assert.NoError(t, err, "TestEagerOp failed")
+	assert.Equal(t, true, value, "TestEagerOp failed")
+	assert.Equal(t, 2, consts, "TestEagerOp failed")
+}
+
+func TestPureOp(t *testing.T) {
+	var calls int
+	add := func(left interface{}, right Expr, ctx interface{}) (interface{}, error) {
+		calls++
+		return testAdd(left, right, ctx)
+	}
+
+	p := NewParser(testVarFactory,
+		WithOp("+", add, Pure),
+		WithOp("-", add),
+		WithVarType("num:", Num))
+
+	ctx := make(map[string]string)
+	ctx["a"] = "1"
+
+	expr, err := p.Parse([]byte("1 + 2 + {num:a} + (3 + 4)"), nil)
+	assert.NoError(t, err, "TestPureOp failed")
+	assert.Equal(t, 2, calls, "TestPureOp failed")
+
+	n := expr.(*node)
+	assert.Equal(t, 3, len(n.exprs), "TestPureOp failed")
+	assert.Equal(t, "1 + 2", n.exprs[0].(sourceExpr).sourceText(), "TestPureOp failed")
+	assert.Equal(t, "(3 + 4)", n.exprs[2].(sourceExpr).sourceText(), "TestPureOp failed")
+
+	value, err := expr.Exec(ctx)
+	assert.NoError(t, err, "TestPureOp failed")
+	assert.Equal(t, int64(11), value, "TestPureOp failed")
+	assert.Equal(t, 4, calls, "TestPureOp failed")
+
+	expr, err = p.Parse([]byte("1 + 2 + 3"), nil)
+	assert.NoError(t, err, "TestPureOp failed")
+	value, err = expr.Exec(ctx)
+	assert.NoError(t, err, "TestPureOp failed")
+	assert.Equal(t, int64(6), value, "TestPureOp failed")
+	assert.Equal(t, 0, len(expr.(*node).ops), "TestPureOp failed")
+
+	calls = 0
+	expr, err = p.Parse([]byte("1 - 2"), nil)
+	assert.NoError(t, err, "TestPureOp failed")
+	assert.Equal(t, 0, calls, "TestPureOp failed")
+
+	expr, err = p.Parse([]byte("1 + a"), nil)
+	assert.NoError(t, err, "TestPureOp failed")
+	_, err = expr.Exec(ctx)
+	assert.Error(t, err, "TestPureOp failed")
+}
+
+func TestCommutativeOp(t *testing.T) {
+	var calls int
+	eq := func(left interface{}, right Expr, ctx interface{}) (interface{}, error) {
+		calls++
+		return testEqual(left, right, ctx)
+	}
+
+	p := NewParser(testVarFactory,
+		WithOp("==", eq),
+		WithOp("&&", testAndLogic, Commutative),
+		WithVarType("num:", Num),
+		WithVarType("bool:", Bool))
+
+	ctx := make(map[string]string)
+	ctx["a"] = "1"
+	ctx["f"] = "false"
+
+	expr, err := p.Parse([]byte("({num:a} == 1) && {bool:f}"), nil)
+	assert.NoError(t, err, "TestCommutativeOp failed")
+	value, err := expr.Exec(ctx)
+	assert.NoError(t, err, "TestCommutativeOp failed")
+	assert.Equal(t, false, value, "TestCommutativeOp failed")
+	assert.Equal(t, 0, calls, "TestCommutativeOp failed")
+
+	ctx["f"] = "true"
+	value, err = expr.Exec(ctx)
+	assert.NoError(t, err, "TestCommutativeOp failed")
+	assert.Equal(t, true, value, "TestCommutativeOp failed")
+	assert.Equal(t, 1, calls, "TestCommutativeOp failed")
+
+	// the vars are in the input order after reordered
+	p = NewParser(testVarFactory,
+		WithOp("+", testAdd, Commutative),
+		WithVarType("num:", Num))
+	expr, err = p.Parse([]byte("({num:a} + {num:b}) + {num:c}"), nil)
+	assert.NoError(t, err, "TestCommutativeOp failed")
+	refs := Variables(expr)
+	assert.Equal(t, 3, len(refs), "TestCommutativeOp failed")
+	assert.Equal(t, "a", refs[0].Name, "TestCommutativeOp failed")
+	assert.Equal(t, "b", refs[1].Name, "TestCommutativeOp failed")
+	assert.Equal(t, "c", refs[2].Name, "TestCommutativeOp failed")
+}
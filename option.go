@@ -1,26 +1,248 @@
 package expr
 
+import (
+	"context"
+	"reflect"
+	"sort"
+)
+
 // Option expr option
 type Option func(*options)
 
 type options struct {
 	ops         map[string]CalcFunc
+	ctxOps      map[string]ContextCalcFunc
+	signatures  map[string][]Signature
+	overloads   map[string][]overload
 	typs        map[string]VarType
+	consts      map[string]interface{}
 	defaultType VarType
+	typeCheck   bool
+	budget      *Budget
+	delimiter   byte
+	matcher     MatcherFactory
+	arraySet    bool
+	bareVars    bool
+	macros      map[string]string
+	comments    bool
+	globs       bool
+	attrs       map[string]OpAttr
+
+	maxInputLength int
+	maxDepth       int
+	maxTokens      int
 }
 
 func newOptions() *options {
 	return &options{
 		ops:         make(map[string]CalcFunc),
+		ctxOps:      make(map[string]ContextCalcFunc),
+		signatures:  make(map[string][]Signature),
+		overloads:   make(map[string][]overload),
 		typs:        make(map[string]VarType),
+		consts:      make(map[string]interface{}),
+		attrs:       make(map[string]OpAttr),
+		macros:      make(map[string]string),
 		defaultType: Str,
+		delimiter:   vertical,
+		matcher:     CompileRegexp,
 	}
 }
 
-// WithOp add a op
-func WithOp(symbol string, opFunc CalcFunc) Option {
+func (opts *options) addAttrs(symbol string, attrs []OpAttr) {
+	for _, attr := range attrs {
+		opts.attrs[symbol] |= attr
+	}
+}
+
+func (opts *options) addOverload(symbol string, value overload) {
+	opts.overloads[symbol] = append(opts.overloads[symbol], value)
+	opts.signatures[symbol] = append(opts.signatures[symbol], value.signature)
+}
+
+// WithOp add a op with the attrs, see OpAttr
+func WithOp(symbol string, opFunc CalcFunc, attrs ...OpAttr) Option {
 	return func(opts *options) {
 		opts.ops[symbol] = opFunc
+		opts.addAttrs(symbol, attrs)
+	}
+}
+
+// WithContextOp add a op observes the context passed to ExecContext, the context is
+// context.Background() if the expr is executed by Exec
+func WithContextOp(symbol string, opFunc ContextCalcFunc, attrs ...OpAttr) Option {
+	return func(opts *options) {
+		opts.ctxOps[symbol] = opFunc
+		opts.addAttrs(symbol, attrs)
+		opts.ops[symbol] = func(left interface{}, right Expr, env interface{}) (interface{}, error) {
+			return opFunc(context.Background(), left, right, env)
+		}
+	}
+}
+
+// WithOpAttrs add the attrs to the op, e.g. the op added by WithInt64Op
+func WithOpAttrs(symbol string, attrs ...OpAttr) Option {
+	return func(opts *options) {
+		opts.addAttrs(symbol, attrs)
+	}
+}
+
+// WithOpSignature declare the operand types and the result type of the op, used by the type checker
+func WithOpSignature(symbol string, signatures ...Signature) Option {
+	return func(opts *options) {
+		opts.signatures[symbol] = append(opts.signatures[symbol], signatures...)
+	}
+}
+
+// WithOverload add a implementation of the op for the operand types of the signature,
+// the implementation is chosen at parse time if the operand types are known and
+// WithTypeCheck is set, otherwise at runtime by the operand values. The op added by WithOp is used if no one matched.
+func WithOverload(symbol string, signature Signature, opFunc CalcFunc) Option {
+	return func(opts *options) {
+		opts.addOverload(symbol, overload{
+			signature: signature,
+			fn:        opFunc,
+		})
+	}
+}
+
+// WithInt64Op add a overload of the op for num operands, compiled programs call the fn without boxing
+func WithInt64Op(symbol string, fn func(int64, int64) int64) Option {
+	return func(opts *options) {
+		opts.addOverload(symbol, overload{
+			signature: Signature{Left: Num, Right: Num, Result: Num},
+			fn:        int64CalcFunc(fn),
+			native:    fn,
+		})
+	}
+}
+
+// WithInt64CmpOp add a overload of the op for num operands returns bool, compiled programs call the fn without boxing
+func WithInt64CmpOp(symbol string, fn func(int64, int64) bool) Option {
+	return func(opts *options) {
+		opts.addOverload(symbol, overload{
+			signature: Signature{Left: Num, Right: Num, Result: Bool},
+			fn:        int64CmpCalcFunc(fn),
+			native:    fn,
+		})
+	}
+}
+
+// WithStrCmpOp add a overload of the op for str operands returns bool, compiled programs call the fn without boxing
+func WithStrCmpOp(symbol string, fn func(string, string) bool) Option {
+	return func(opts *options) {
+		opts.addOverload(symbol, overload{
+			signature: Signature{Left: Str, Right: Str, Result: Bool},
+			fn:        strCmpCalcFunc(fn),
+			native:    fn,
+		})
+	}
+}
+
+// WithAndOp add the short-circuit logical and op for bool operands
+func WithAndOp(symbol string) Option {
+	return func(opts *options) {
+		opts.addOverload(symbol, overload{
+			signature: Signature{Left: Bool, Right: Bool, Result: Bool},
+			fn:        logicCalcFunc(false),
+			native:    logicAnd,
+		})
+	}
+}
+
+// WithOrOp add the short-circuit logical or op for bool operands
+func WithOrOp(symbol string) Option {
+	return func(opts *options) {
+		opts.addOverload(symbol, overload{
+			signature: Signature{Left: Bool, Right: Bool, Result: Bool},
+			fn:        logicCalcFunc(true),
+			native:    logicOr,
+		})
+	}
+}
+
+// WithGlobOp add the op matches the str left value with the glob right value, see MatchGlob.
+// The glob literals `#pattern#` are enabled by the option.
+func WithGlobOp(symbol string) Option {
+	return func(opts *options) {
+		opts.globs = true
+		opts.addOverload(symbol, overload{
+			signature: Signature{Left: Str, Right: Glob, Result: Bool},
+			fn:        MatchGlob,
+		})
+	}
+}
+
+// WithArraySet compile the array literals into the *Set at parse time, the ops get
+// the *Set instead of the []string, see In
+func WithArraySet() Option {
+	return func(opts *options) {
+		opts.arraySet = true
+	}
+}
+
+// WithBareVars treat the bare identifiers like `user.name` as the vars with the default
+// var type, `user.age:num` casts the var to the type by the VarType name or the var type
+// symbol without the `:`. The numbers, `true`, `false` and the consts are not vars.
+func WithBareVars() Option {
+	return func(opts *options) {
+		opts.bareVars = true
+	}
+}
+
+// WithMacro add a macro, `@name` is expanded to the expr parsed from the source at parse
+// time. The macros can use the other macros, the errors of the macro source including the
+// cycles are returned by the Parse uses the macro. The name must be a identifier, e.g.
+// `user.adult`, the invalid names are returned by every Parse.
+func WithMacro(name string, source string) Option {
+	return func(opts *options) {
+		opts.macros[name] = source
+	}
+}
+
+// WithComments enable the `//` line comments and the `/* */` block comments outside
+// the literals, see Comments. The line comments are disabled if the regexp delimiter
+// is `/`, so that `//` is the empty regexp literal.
+func WithComments() Option {
+	return func(opts *options) {
+		opts.comments = true
+	}
+}
+
+// WithTypeCheck check the types of the expr after parsed, see Check. The bare `true`
+// and `false` are the bool constants with the option.
+func WithTypeCheck() Option {
+	return func(opts *options) {
+		opts.typeCheck = true
+	}
+}
+
+// WithBudget limit the cost of executing the exprs, the expr returns BudgetError if
+// the budget exceeded. The array and regexp literals are checked at parse time.
+func WithBudget(budget Budget) Option {
+	return func(opts *options) {
+		opts.budget = &budget
+	}
+}
+
+// WithMaxInputLength the Parse fails with ErrMaxInputLength if the input is longer than max bytes
+func WithMaxInputLength(max int) Option {
+	return func(opts *options) {
+		opts.maxInputLength = max
+	}
+}
+
+// WithMaxDepth the Parse fails with ErrMaxDepth if the parens nested deeper than max
+func WithMaxDepth(max int) Option {
+	return func(opts *options) {
+		opts.maxDepth = max
+	}
+}
+
+// WithMaxTokens the Parse fails with ErrMaxTokens if the input has more than max tokens
+func WithMaxTokens(max int) Option {
+	return func(opts *options) {
+		opts.maxTokens = max
 	}
 }
 
@@ -37,3 +259,37 @@ func WithDefaultVarType(value VarType) Option {
 		opts.defaultType = value
 	}
 }
+
+// WithConst add a constant, `{name}` and the bare identifier name are resolved to the
+// value at parse time instead of calling the VarExprFactory. The integer values are
+// converted to int64, `{num:name}` converts the value to the declared var type.
+func WithConst(name string, value interface{}) Option {
+	return func(opts *options) {
+		opts.consts[name] = value
+	}
+}
+
+// WithRegexpDelimiter set the delimiter of the regexp literals, default is `|`,
+// e.g. `/` for the `/pattern/flags` literals
+func WithRegexpDelimiter(delimiter byte) Option {
+	return func(opts *options) {
+		opts.delimiter = delimiter
+	}
+}
+
+// WithMatcher set the factory of the regexp literal values, default is CompileRegexp
+func WithMatcher(factory MatcherFactory) Option {
+	return func(opts *options) {
+		opts.matcher = factory
+	}
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+
+	sort.Strings(keys)
+	return keys
+}
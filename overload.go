@@ -0,0 +1,65 @@
+package expr
+
+import (
+	"fmt"
+)
+
+type overload struct {
+	signature Signature
+	fn        CalcFunc
+	// native the typed implementation used by compiled programs
+	native interface{}
+}
+
+// bind returns the implementation of the operand types, nil if no one matched
+func (op *operator) bind(left, right VarType) *overload {
+	for idx := range op.overloads {
+		if op.overloads[idx].signature.match(left, right) {
+			return &op.overloads[idx]
+		}
+	}
+
+	return nil
+}
+
+// dispatch chooses the implementation by the left value type, the right expr is executed
+// to choose by the right value type only if more than one implementation accept the left
+// value, so that the only implementation like the logic ops can short-circuit.
+func (op *operator) dispatch(left interface{}, right Expr, ctx interface{}) (interface{}, error) {
+	leftType := typeOfValue(left)
+
+	var candidates []overload
+	for _, o := range op.overloads {
+		if matchType(o.signature.Left, leftType) {
+			candidates = append(candidates, o)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return op.callFallback(left, right, ctx)
+	} else if len(candidates) == 1 {
+		return candidates[0].fn(left, right, ctx)
+	}
+
+	value, err := right.Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rightType := typeOfValue(value)
+	for _, o := range candidates {
+		if matchType(o.signature.Right, rightType) {
+			return o.fn(left, newValueExpr(value), ctx)
+		}
+	}
+
+	return op.callFallback(left, newValueExpr(value), ctx)
+}
+
+func (op *operator) callFallback(left interface{}, right Expr, ctx interface{}) (interface{}, error) {
+	if op.fallback == nil {
+		return nil, fmt.Errorf("op <%s> not support %T", op.symbol, left)
+	}
+
+	return op.fallback(left, right, ctx)
+}
@@ -0,0 +1,142 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverload(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOverload("==", Signature{Left: Num, Right: Num, Result: Bool}, testEqual),
+		WithOverload("==", Signature{Left: Str, Right: Str, Result: Bool}, testStrEqual),
+		WithVarType("num:", Num),
+		WithVarType("str:", Str))
+
+	ctx := make(map[string]string)
+	ctx["1"] = "1"
+	ctx["2"] = "abc"
+
+	expr, err := p.Parse([]byte("{num:1}==1"), nil)
+	assert.NoError(t, err, "TestOverload failed")
+	value, err := expr.Exec(ctx)
+	assert.NoError(t, err, "TestOverload failed")
+	assert.Equal(t, true, value, "TestOverload failed")
+
+	expr, err = p.Parse([]byte("{str:2}==abc"), nil)
+	assert.NoError(t, err, "TestOverload failed")
+	value, err = expr.Exec(ctx)
+	assert.NoError(t, err, "TestOverload failed")
+	assert.Equal(t, true, value, "TestOverload failed")
+
+	typ, err := Check(expr)
+	assert.NoError(t, err, "TestOverload failed")
+	assert.Equal(t, Bool, typ, "TestOverload failed")
+
+	_, err = p.Parse([]byte("{str:2}==1"), nil)
+	assert.NoError(t, err, "TestOverload failed")
+
+	// the errors of binding are returned with WithTypeCheck
+	p = NewParser(testVarFactory,
+		WithOverload("==", Signature{Left: Num, Right: Num, Result: Bool}, testEqual),
+		WithOverload("==", Signature{Left: Str, Right: Str, Result: Bool}, testStrEqual),
+		WithVarType("num:", Num),
+		WithVarType("str:", Str),
+		WithTypeCheck())
+	expr, err = p.Parse([]byte("{num:1}==1"), nil)
+	assert.NoError(t, err, "TestOverload failed")
+	value, err = expr.Exec(ctx)
+	assert.NoError(t, err, "TestOverload failed")
+	assert.Equal(t, true, value, "TestOverload failed")
+
+	_, err = p.Parse([]byte("{str:2}==1"), nil)
+	assert.Error(t, err, "TestOverload failed")
+}
+
+func TestOverloadDispatch(t *testing.T) {
+	op := &operator{
+		symbol: "==",
+		overloads: []overload{
+			{signature: Signature{Left: Num, Right: Num, Result: Bool}, fn: testEqual},
+			{signature: Signature{Left: Str, Right: Str, Result: Bool}, fn: testStrEqual},
+			{signature: Signature{Left: Str, Right: Array, Result: Bool}, fn: testStrIn},
+		},
+	}
+
+	value, err := op.dispatch(int64(1), &constInt64{value: 1}, nil)
+	assert.NoError(t, err, "TestOverloadDispatch failed")
+	assert.Equal(t, true, value, "TestOverloadDispatch failed")
+
+	value, err = op.dispatch("a", &constString{value: "a"}, nil)
+	assert.NoError(t, err, "TestOverloadDispatch failed")
+	assert.Equal(t, true, value, "TestOverloadDispatch failed")
+
+	value, err = op.dispatch("a", &constArray{values: []string{"b", "a"}}, nil)
+	assert.NoError(t, err, "TestOverloadDispatch failed")
+	assert.Equal(t, true, value, "TestOverloadDispatch failed")
+
+	_, err = op.dispatch(true, &constString{value: "a"}, nil)
+	assert.Error(t, err, "TestOverloadDispatch failed")
+
+	// the only implementation accepts the left value gets the right expr
+	_, err = op.dispatch(int64(1), &constString{value: "a"}, nil)
+	assert.Error(t, err, "TestOverloadDispatch failed")
+	assert.Contains(t, err.Error(), "is not int64", "TestOverloadDispatch failed")
+}
+
+func TestOverloadShortCircuit(t *testing.T) {
+	calls := 0
+	p := NewParser(func(value []byte, valueType VarType) (Expr, error) {
+		return &testCountedVarExpr{attr: string(value), calls: &calls}, nil
+	},
+		WithStrCmpOp("==", func(a, b string) bool { return a == b }),
+		WithAndOp("&&"),
+		WithOrOp("||"),
+		WithVarType("str:", Str),
+		WithVarType("bool:", Bool))
+
+	ctx := make(map[string]interface{})
+	ctx["b"] = "x"
+
+	cases := []struct {
+		input  string
+		a      bool
+		result bool
+		calls  int
+	}{
+		{"{bool:a} && ({str:b} == x)", false, false, 1},
+		{"{bool:a} && ({str:b} == x)", true, true, 2},
+		{"{bool:a} || ({str:b} == x)", true, true, 1},
+		{"{bool:a} || ({str:b} == x)", false, true, 2},
+	}
+
+	for _, c := range cases {
+		ctx["a"] = c.a
+		expr, err := p.Parse([]byte(c.input), nil)
+		assert.NoError(t, err, "TestOverloadShortCircuit failed")
+
+		calls = 0
+		value, err := expr.Exec(ctx)
+		assert.NoError(t, err, "TestOverloadShortCircuit failed")
+		assert.Equal(t, c.result, value, "TestOverloadShortCircuit failed: %s", c.input)
+		assert.Equal(t, c.calls, calls, "TestOverloadShortCircuit failed: %s", c.input)
+
+		prog, err := Compile(expr)
+		assert.NoError(t, err, "TestOverloadShortCircuit failed")
+		calls = 0
+		value, err = prog.Exec(ctx)
+		assert.NoError(t, err, "TestOverloadShortCircuit failed")
+		assert.Equal(t, c.result, value, "TestOverloadShortCircuit failed: %s", c.input)
+		assert.Equal(t, c.calls, calls, "TestOverloadShortCircuit failed: %s", c.input)
+	}
+}
+
+type testCountedVarExpr struct {
+	attr  string
+	calls *int
+}
+
+func (expr *testCountedVarExpr) Exec(ctx interface{}) (interface{}, error) {
+	*expr.calls++
+	return ctx.(map[string]interface{})[expr.attr], nil
+}
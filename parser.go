@@ -1,35 +1,23 @@
 package expr
 
 import (
+	"errors"
 	"fmt"
-	"regexp"
-	"strings"
+	"unicode/utf8"
 
 	"github.com/fagongzi/util/format"
 )
 
 const (
-	tokenUnknown    = 10000
-	tokenLeftParen  = 1
-	tokenRightParen = 2
-	tokenVarStart   = 3
-	tokenVarEnd     = 4
-	tokenLiteral    = 5
-	tokenRegexp     = 6
-	tokenArrayStart = 7
-	tokenArrayEnd   = 8
-	tokenCustom     = 100
-
-	slash                     = '\\'
-	quotation                 = '"'
-	vertical                  = '|'
-	arrayLeft                 = '['
-	arrayRight                = ']'
-	quotationConversion  byte = 0x00
-	slashConversion      byte = 0x01
-	verticalConversion   byte = 0x02
-	arrayLeftConversion  byte = 0x03
-	arrayRightConversion byte = 0x04
+	slash           = '\\'
+	quotation       = '"'
+	singleQuotation = '\''
+	backtick        = '`'
+	vertical        = '|'
+	arrayLeft       = '['
+	arrayRight      = ']'
+	comma           = ','
+	hash            = '#'
 )
 
 var (
@@ -40,7 +28,20 @@ var (
 	symbolArrayStart = []byte("[")
 	symbolArrayEnd   = []byte("]")
 	symbolLiteral    = []byte{quotation}
-	symbolRegexp     = []byte{vertical}
+	symbolSingle     = []byte{singleQuotation}
+	symbolRaw        = []byte{backtick}
+	symbolGlob       = []byte{hash}
+
+	symbolBlockCommentEnd = []byte("*/")
+)
+
+var (
+	// ErrMaxInputLength the input exceeds the max length
+	ErrMaxInputLength = errors.New("exceed max input length")
+	// ErrMaxDepth the input exceeds the max nesting depth
+	ErrMaxDepth = errors.New("exceed max nesting depth")
+	// ErrMaxTokens the input exceeds the max number of tokens
+	ErrMaxTokens = errors.New("exceed max number of tokens")
 )
 
 // CalcFunc a calc function returns a result
@@ -52,11 +53,20 @@ type Parser interface {
 }
 
 type parser struct {
+	input     []byte
 	expr      *node
 	stack     stack
 	prevToken int
+	tokens    int
+	opSpan    Span
 	lexer     Lexer
 	template  *parserTemplate
+	cb        func(Expr)
+	casts     map[*node]*castExpr
+	// textStart the offset after the previous token, the scanned text starts at it
+	textStart int
+	// macroStack the names of the macros being parsed
+	macroStack []string
 }
 
 type parserTemplate struct {
@@ -64,22 +74,36 @@ type parserTemplate struct {
 	startToken      int
 	startConversion byte
 	opsTokens       map[int]string
-	opsFunc         map[int]CalcFunc
+	ops             map[int]*operator
+	opsBySymbol     map[string]*operator
 	varTypes        map[int]VarType
 	varTokens       map[int]string
+	castTypes       map[int]VarType
+	castTokens      map[int]string
+	macroTokens     map[int]string
+	macroNames      map[int]string
+	macros          map[string]*macro
 	factory         VarExprFactory
+	// macroErr the error of the invalid macro names
+	macroErr error
 }
 
 // NewParser returns a expr parser
 func NewParser(factory VarExprFactory, opts ...Option) Parser {
 	p := &parserTemplate{
-		opts:       newOptions(),
-		factory:    factory,
-		opsTokens:  make(map[int]string),
-		opsFunc:    make(map[int]CalcFunc),
-		varTypes:   make(map[int]VarType),
-		varTokens:  make(map[int]string),
-		startToken: tokenCustom,
+		opts:        newOptions(),
+		factory:     factory,
+		opsTokens:   make(map[int]string),
+		ops:         make(map[int]*operator),
+		opsBySymbol: make(map[string]*operator),
+		varTypes:    make(map[int]VarType),
+		varTokens:   make(map[int]string),
+		castTypes:   make(map[int]VarType),
+		castTokens:  make(map[int]string),
+		macroTokens: make(map[int]string),
+		macroNames:  make(map[int]string),
+		macros:      make(map[string]*macro),
+		startToken:  tokenCustom,
 	}
 
 	for _, opt := range opts {
@@ -95,15 +119,64 @@ func (p *parserTemplate) init() {
 		p.addOP(op, opFunc)
 	}
 
+	for op := range p.opts.overloads {
+		if _, ok := p.opts.ops[op]; !ok {
+			p.addOP(op, nil)
+		}
+	}
+
 	for symbol, valueType := range p.opts.typs {
 		p.addVarType(symbol, valueType)
 	}
+
+	casts := castNames()
+	for _, name := range sortedKeys(casts) {
+		p.addCast(name+string(symbolLeftParen), casts[name])
+	}
+
+	for _, name := range sortedKeys(p.opts.macros) {
+		if !isIdentifier([]byte(name)) {
+			if p.macroErr == nil {
+				p.macroErr = fmt.Errorf("invalid macro name %q", name)
+			}
+			continue
+		}
+
+		p.startToken++
+		p.macroTokens[p.startToken] = string(macroPrefix) + name
+		p.macroNames[p.startToken] = name
+	}
+
+	p.parseMacros()
 }
 
 func (p *parserTemplate) addOP(op string, calcFunc CalcFunc) {
 	p.startToken++
 	p.opsTokens[p.startToken] = op
-	p.opsFunc[p.startToken] = calcFunc
+	value := &operator{
+		symbol:     op,
+		fn:         calcFunc,
+		ctxFn:      p.opts.ctxOps[op],
+		signatures: p.opts.signatures[op],
+		overloads:  p.opts.overloads[op],
+		attrs:      p.opts.attrs[op],
+	}
+	if len(value.overloads) > 0 {
+		value.fallback = calcFunc
+		value.fn = value.dispatch
+	}
+	value.fn = value.calcFunc(value.fn)
+	if value.ctxFn != nil && value.attrs.Has(Eager) {
+		value.ctxFn = eagerContextCalcFunc(value.ctxFn)
+	}
+	p.ops[p.startToken] = value
+	p.opsBySymbol[op] = value
+}
+
+func (p *parserTemplate) addCast(symbol string, varType VarType) {
+	p.startToken++
+	p.castTokens[p.startToken] = symbol
+	p.castTypes[p.startToken] = varType
 }
 
 func (p *parserTemplate) addVarType(symbol string, varType VarType) {
@@ -113,34 +186,121 @@ func (p *parserTemplate) addVarType(symbol string, varType VarType) {
 }
 
 func (p *parserTemplate) Parse(input []byte, cb func(Expr)) (Expr, error) {
+	if p.macroErr != nil {
+		return nil, p.macroErr
+	}
+
+	if max := p.opts.maxInputLength; max > 0 && len(input) > max {
+		return nil, fmt.Errorf("%w: %d", ErrMaxInputLength, max)
+	}
+
 	return p.newParser(input).parse(cb)
 }
 
+// finish binds the budget to the parsed expr, checks the types and binds the overloads
+// if WithTypeCheck is set, and optimizes the expr by the op attrs
+func (p *parserTemplate) finish(expr Expr) (Expr, error) {
+	if n, ok := expr.(*node); ok {
+		n.budget = p.opts.budget
+	}
+
+	reorder(expr)
+	if p.opts.typeCheck {
+		c := &checker{bind: true}
+		if _, err := c.check(expr); err != nil {
+			return nil, err
+		}
+	}
+
+	if n, ok := expr.(*node); ok {
+		fold(n)
+	}
+
+	return expr, nil
+}
+
+func (p *parserTemplate) template() *parserTemplate {
+	return p
+}
+
+// templateOf returns the parserTemplate of the parser created by NewParser
+func templateOf(parser Parser) (*parserTemplate, error) {
+	if p, ok := parser.(interface{ template() *parserTemplate }); ok {
+		return p.template(), nil
+	}
+
+	return nil, fmt.Errorf("parser %T not support", parser)
+}
+
+// newVarExpr returns the var expr created by the factory, or the const expr if the
+// factory returns a const, e.g. the env values, so that it's the same as the consts
+func (p *parserTemplate) newVarExpr(name string, varType VarType, text string, span Span) (Expr, error) {
+	value, err := p.factory([]byte(name), varType)
+	if err != nil {
+		return nil, err
+	}
+
+	if isConst(value) {
+		value.(sourceExpr).setSourceText(text)
+		return value, nil
+	}
+
+	return &varExpr{
+		source:  source{text: text},
+		name:    name,
+		varType: varType,
+		span:    span,
+		expr:    value,
+	}, nil
+}
+
 func (p *parserTemplate) registerInternal(lexer Lexer) {
 	lexer.AddSymbol(symbolLeftParen, tokenLeftParen)
 	lexer.AddSymbol(symbolRightParen, tokenRightParen)
 	lexer.AddSymbol(symbolVarStart, tokenVarStart)
 	lexer.AddSymbol(symbolVarEnd, tokenVarEnd)
 	lexer.AddSymbol(symbolLiteral, tokenLiteral)
+	lexer.AddSymbol(symbolSingle, tokenSingleLiteral)
+	lexer.AddSymbol(symbolRaw, tokenRawLiteral)
 	lexer.AddSymbol(symbolArrayStart, tokenArrayStart)
 	lexer.AddSymbol(symbolArrayEnd, tokenArrayEnd)
-	lexer.AddSymbol(symbolRegexp, tokenRegexp)
+	lexer.AddSymbol([]byte{p.opts.delimiter}, tokenRegexp)
+	if p.opts.globs {
+		lexer.AddSymbol(symbolGlob, tokenGlob)
+	}
 
 	for tokenValue, token := range p.opsTokens {
 		lexer.AddSymbol([]byte(token), tokenValue)
 	}
 
 	for tokenValue, token := range p.varTokens {
-		p.startToken++
+		lexer.AddSymbol([]byte(token), tokenValue)
+	}
+
+	for tokenValue, token := range p.castTokens {
+		lexer.AddSymbol([]byte(token), tokenValue)
+	}
+
+	for tokenValue, token := range p.macroTokens {
 		lexer.AddSymbol([]byte(token), tokenValue)
 	}
 }
 
-func (p *parserTemplate) newParser(input []byte) *parser {
-	lexer := NewScanner(conversion(input))
+// newLexer returns the lexer with the internal symbols registered
+func (p *parserTemplate) newLexer(input []byte) Lexer {
+	lexer := NewScanner(input)
+	if p.opts.comments {
+		lexer.(CommentLexer).ScanComments()
+	}
 	p.registerInternal(lexer)
+	return lexer
+}
+
+func (p *parserTemplate) newParser(input []byte) *parser {
+	lexer := p.newLexer(input)
 
 	return &parser{
+		input:     input,
 		expr:      &node{},
 		prevToken: tokenUnknown,
 		template:  p,
@@ -149,9 +309,12 @@ func (p *parserTemplate) newParser(input []byte) *parser {
 }
 
 func (p *parser) parse(cb func(Expr)) (Expr, error) {
+	p.cb = cb
 	p.stack.push(p.expr)
 	for {
-		p.lexer.NextToken()
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
 		token := p.lexer.Token()
 
 		var err error
@@ -162,14 +325,22 @@ func (p *parser) parse(cb func(Expr)) (Expr, error) {
 		} else if token == tokenVarStart {
 			err = p.doVarStart(cb)
 			token = tokenVarEnd
-		} else if token == tokenLiteral {
-			err = p.doLiteral()
+		} else if isLiteralToken(token) {
+			err = p.doLiteral(token)
 		} else if token == tokenArrayStart {
 			err = p.doArray()
-		} else if token == tokenRegexp {
-			err = p.doRegexp()
-		} else if _, ok := p.template.opsTokens[token]; ok {
+		} else if token == tokenRegexp || token == tokenGlob {
+			err = p.doPattern(token)
+		} else if name, ok := p.template.macroNames[token]; ok {
+			err = p.doMacro(name)
+			token = tokenVarEnd
+		} else if varType, ok := p.template.castTypes[token]; ok {
+			err = p.doCast(varType)
+			token = tokenLeftParen
+		} else if op, ok := p.template.opsTokens[token]; ok {
 			err = p.doOp()
+			end := p.lexer.TokenIndex() + 1
+			p.opSpan = p.span(end-len(op), end)
 		} else if _, ok := p.template.varTypes[token]; ok {
 			err = p.doVarType()
 		} else if token == TokenEOI {
@@ -178,125 +349,233 @@ func (p *parser) parse(cb func(Expr)) (Expr, error) {
 				return nil, err
 			}
 
-			return p.stack.pop(), nil
+			expr := p.stack.pop()
+			p.expr.span = Span{Start: 0, End: len(p.input)}
+			p.expr.setSourceText(string(p.input))
+			if c, ok := p.lexer.(CommentLexer); ok {
+				p.expr.comments = append(p.expr.comments, c.Comments()...)
+			}
+			return p.template.finish(expr)
 		}
 
 		if err != nil {
 			return nil, err
 		}
 
-		if token != tokenLiteral &&
+		if max := p.template.opts.maxDepth; max > 0 && len(p.stack.nodes)-1 > max {
+			return nil, fmt.Errorf("%w: %d before %s", ErrMaxDepth, max, p.position().location())
+		}
+
+		if !isLiteralToken(token) &&
 			token != tokenArrayStart &&
 			token != tokenArrayEnd &&
-			token != tokenRegexp {
+			token != tokenRegexp &&
+			token != tokenGlob {
 			p.prevToken = token
 		}
 	}
 }
 
+func (p *parser) nextToken() error {
+	p.textStart = p.lexer.TokenIndex() + 1
+	p.lexer.NextToken()
+	if c, ok := p.lexer.(CommentLexer); ok && c.Err() != nil {
+		return c.Err()
+	}
+
+	if p.lexer.Token() == TokenEOI {
+		return nil
+	}
+
+	p.tokens++
+	if max := p.template.opts.maxTokens; max > 0 && p.tokens > max {
+		return fmt.Errorf("%w: %d before %s", ErrMaxTokens, max, p.position().location())
+	}
+
+	return nil
+}
+
 func (p *parser) doLeftParen() error {
+	n := &node{span: p.span(p.lexer.TokenIndex(), p.lexer.TokenIndex())}
+	return p.openGroup(n, n)
+}
+
+func (p *parser) doCast(varType VarType) error {
+	end := p.lexer.TokenIndex() + 1
+	n := &node{span: p.span(end-1, end-1)}
+	cast := &castExpr{
+		varType: varType,
+		span:    p.span(end-len(p.lexer.TokenSymbol(p.lexer.Token())), end),
+		expr:    n,
+	}
+	if p.casts == nil {
+		p.casts = make(map[*node]*castExpr)
+	}
+	p.casts[n] = cast
+	return p.openGroup(n, cast)
+}
+
+// openGroup adds the expr of the group to the current node, and pushes the
+// group node closed by the right paren
+func (p *parser) openGroup(n *node, expr Expr) error {
 	if p.prevToken == tokenUnknown { // (a+b)
-		p.stack.append(&node{})
+		p.stack.current().add(expr)
 	} else if p.prevToken == tokenLeftParen { // ((a+b)*10)
-		p.stack.append(&node{})
-	} else if fn, ok := p.template.opsFunc[p.prevToken]; ok { // 10 * (a+b)
-		p.stack.appendWithOP(fn, &node{})
+		p.stack.current().add(expr)
+	} else if op, ok := p.template.ops[p.prevToken]; ok { // 10 * (a+b)
+		p.stack.current().appendWithOP(op, p.opSpan, expr)
 	} else {
-		return fmt.Errorf("unexpect token <%s> before %d",
-			p.lexer.TokenSymbol(p.prevToken),
-			p.lexer.TokenIndex())
+		return p.unexpected()
 	}
 
+	p.stack.push(n)
 	p.lexer.SkipString()
 	return nil
 }
 
 func (p *parser) doRightParen() error {
+	if len(p.stack.nodes) == 1 {
+		return fmt.Errorf("unexpect token <%s> before %s",
+			p.lexer.TokenSymbol(tokenRightParen),
+			p.position().location())
+	}
+
 	var err error
 	if p.prevToken == tokenRightParen || p.prevToken == tokenVarEnd { // (c + (a + b))
-		p.stack.pop()
+		p.closeGroup()
 		p.lexer.SkipString()
-	} else if fn, ok := p.template.opsFunc[p.prevToken]; ok { // (a + b)
-		expr, err := newConstExpr(p.lexer.ScanString())
+	} else if op, ok := p.template.ops[p.prevToken]; ok { // (a + b)
+		expr, err := p.newConstExpr(p.lexer.ScanString())
 		if err != nil {
 			return err
 		}
-		p.stack.current().appendWithOP(fn, expr)
-		p.stack.pop()
+		p.stack.current().appendWithOP(op, p.opSpan, expr)
+		p.closeGroup()
+	} else if p.prevToken == tokenLeftParen { // ("a")
+		value := p.lexer.ScanString()
+		if len(value) == 0 {
+			return p.unexpected()
+		}
+
+		expr, err := p.newConstExpr(value)
+		if err != nil {
+			return err
+		}
+		p.stack.current().append(expr)
+		p.closeGroup()
 	} else {
-		return fmt.Errorf("unexpect token <%s> before %d",
-			p.lexer.TokenSymbol(p.prevToken),
-			p.lexer.TokenIndex())
+		return p.unexpected()
 	}
 
 	return err
 }
 
+// closeGroup pops the current node closed by the right paren
+func (p *parser) closeGroup() {
+	n := p.stack.current()
+	n.span.End = p.lexer.TokenIndex() + 1
+	n.setSourceText(string(p.input[n.span.Start:n.span.End]))
+	if cast, ok := p.casts[n]; ok {
+		cast.span.End = n.span.End
+		cast.setSourceText(string(p.input[cast.span.Start:cast.span.End]))
+	}
+	p.stack.pop()
+}
+
 func (p *parser) doVarStart(cb func(Expr)) error {
 	if p.prevToken == tokenUnknown { // {
 		p.stack.append(&node{})
 	} else if p.prevToken == tokenLeftParen { // ({
 		p.stack.append(&node{})
-	} else if fn, ok := p.template.opsFunc[p.prevToken]; ok { // a + {
-		p.stack.appendWithOP(fn, &node{})
+	} else if op, ok := p.template.ops[p.prevToken]; ok { // a + {
+		p.stack.appendWithOP(op, p.opSpan, &node{})
 	} else {
-		return fmt.Errorf("unexpect token <%s> before %d",
-			p.lexer.TokenSymbol(p.prevToken),
-			p.lexer.TokenIndex())
+		return p.unexpected()
 	}
 
 	p.lexer.SkipString()
 
+	start := p.lexer.TokenIndex()
 	varType := p.template.opts.defaultType
+	typed := false
 	for {
-		p.lexer.NextToken()
+		if err := p.nextToken(); err != nil {
+			return err
+		}
 		token := p.lexer.Token()
 		if token == TokenEOI {
 			return fmt.Errorf("missing }")
 		} else if t, ok := p.template.varTypes[token]; ok {
 			varType = t
+			typed = true
 			p.lexer.SkipString()
 		} else if p.lexer.Token() == tokenVarEnd {
 			break
 		}
 	}
 
-	varExpr, err := p.template.factory(p.lexer.ScanString(), varType)
+	span := p.span(start, p.lexer.TokenIndex()+1)
+	text := string(p.input[span.Start:span.End])
+	p.stack.current().span = span
+	p.stack.current().setSourceText(text)
+
+	name := p.lexer.ScanString()
+	if value, ok := p.template.opts.consts[string(name)]; ok {
+		expr := newValueExpr(value)
+		if typed && varType != Any {
+			// the const is converted to the declared type, e.g. `{num:NAME}`
+			v, _ := expr.Exec(nil)
+			v, err := castValue(v, varType)
+			if err != nil {
+				return fmt.Errorf("const %s before %s: %w", name, p.position().location(), err)
+			}
+			expr = newValueExpr(v)
+		}
+		expr.(sourceExpr).setSourceText(text)
+		p.stack.current().append(expr)
+		p.stack.pop()
+		return nil
+	}
+
+	value, err := p.template.newVarExpr(string(name), varType, text, span)
 	if err != nil {
 		return err
 	}
 
-	if cb != nil {
-		cb(varExpr)
+	if v, ok := value.(*varExpr); ok && cb != nil {
+		cb(v.expr)
 	}
-	p.stack.current().append(varExpr)
+	p.stack.current().append(value)
 	p.stack.pop()
 	return nil
 }
 
-func (p *parser) doLiteral() error {
-	if _, ok := p.template.opsFunc[p.prevToken]; ok || p.prevToken == tokenUnknown { // a + "
+func (p *parser) doLiteral(token int) error {
+	if _, ok := p.template.ops[p.prevToken]; ok ||
+		p.prevToken == tokenUnknown || p.prevToken == tokenLeftParen { // a + "
 		for {
-			p.lexer.NextToken()
+			if err := p.nextToken(); err != nil {
+				return err
+			}
 			if p.lexer.Token() == TokenEOI {
-				return fmt.Errorf("missing \"")
-			} else if p.lexer.Token() == tokenLiteral {
+				return fmt.Errorf("missing %s", p.lexer.TokenSymbol(token))
+			} else if p.lexer.Token() == token {
 				break
 			}
 		}
 	} else {
-		return fmt.Errorf("unexpect token <%s> before %d",
-			p.lexer.TokenSymbol(p.prevToken),
-			p.lexer.TokenIndex())
+		return p.unexpected()
 	}
 
 	return nil
 }
 
 func (p *parser) doArray() error {
-	if _, ok := p.template.opsFunc[p.prevToken]; ok { // a in [
+	if _, ok := p.template.ops[p.prevToken]; ok || p.prevToken == tokenLeftParen { // a in [
 		for {
-			p.lexer.NextToken()
+			if err := p.nextToken(); err != nil {
+				return err
+			}
 			if p.lexer.Token() == TokenEOI {
 				return fmt.Errorf("missing ]")
 			} else if p.lexer.Token() == tokenArrayEnd {
@@ -304,28 +583,26 @@ func (p *parser) doArray() error {
 			}
 		}
 	} else {
-		return fmt.Errorf("unexpect token <%s> before %d",
-			p.lexer.TokenSymbol(p.prevToken),
-			p.lexer.TokenIndex())
+		return p.unexpected()
 	}
 
 	return nil
 }
 
-func (p *parser) doRegexp() error {
-	if _, ok := p.template.opsFunc[p.prevToken]; ok { // a + /
+func (p *parser) doPattern(token int) error {
+	if _, ok := p.template.ops[p.prevToken]; ok || p.prevToken == tokenLeftParen { // a ~ |
 		for {
-			p.lexer.NextToken()
+			if err := p.nextToken(); err != nil {
+				return err
+			}
 			if p.lexer.Token() == TokenEOI {
-				return fmt.Errorf("missing /")
-			} else if p.lexer.Token() == tokenRegexp {
+				return fmt.Errorf("missing %s", p.lexer.TokenSymbol(token))
+			} else if p.lexer.Token() == token {
 				break
 			}
 		}
 	} else {
-		return fmt.Errorf("unexpect token <%s> before %d",
-			p.lexer.TokenSymbol(p.prevToken),
-			p.lexer.TokenIndex())
+		return p.unexpected()
 	}
 
 	return nil
@@ -334,31 +611,29 @@ func (p *parser) doRegexp() error {
 func (p *parser) doOp() error {
 	var err error
 	if p.prevToken == tokenUnknown { // 1 +
-		expr, err := newConstExpr(p.lexer.ScanString())
+		expr, err := p.newConstExpr(p.lexer.ScanString())
 		if err != nil {
 			return err
 		}
 		p.stack.current().append(expr)
 	} else if p.prevToken == tokenLeftParen { // (a+
-		expr, err := newConstExpr(p.lexer.ScanString())
+		expr, err := p.newConstExpr(p.lexer.ScanString())
 		if err != nil {
 			return err
 		}
 		p.stack.current().append(expr)
 	} else if p.prevToken == tokenRightParen { // (a+1) +
 		p.lexer.SkipString()
-	} else if fn, ok := p.template.opsFunc[p.prevToken]; ok { // a + b +
-		expr, err := newConstExpr(p.lexer.ScanString())
+	} else if op, ok := p.template.ops[p.prevToken]; ok { // a + b +
+		expr, err := p.newConstExpr(p.lexer.ScanString())
 		if err != nil {
 			return err
 		}
-		p.stack.current().appendWithOP(fn, expr)
+		p.stack.current().appendWithOP(op, p.opSpan, expr)
 	} else if p.prevToken == tokenVarEnd { // {a} +
 		p.lexer.SkipString()
 	} else {
-		return fmt.Errorf("unexpect token <%s> before %d",
-			p.lexer.TokenSymbol(p.prevToken),
-			p.lexer.TokenIndex())
+		return p.unexpected()
 	}
 
 	return err
@@ -368,9 +643,7 @@ func (p *parser) doVarType() error {
 	switch p.prevToken {
 	case tokenVarStart:
 	default:
-		return fmt.Errorf("unexpect token <%s> before %d",
-			p.lexer.TokenSymbol(p.prevToken),
-			p.lexer.TokenIndex())
+		return p.unexpected()
 	}
 
 	p.lexer.SkipString()
@@ -380,46 +653,175 @@ func (p *parser) doVarType() error {
 func (p *parser) doEOI() error {
 	if p.prevToken == tokenRightParen || p.prevToken == tokenVarEnd { // (a+b)
 
-	} else if fn, ok := p.template.opsFunc[p.prevToken]; ok { // a + b
-		expr, err := newConstExpr(p.lexer.ScanString())
+	} else if op, ok := p.template.ops[p.prevToken]; ok { // a + b
+		expr, err := p.newConstExpr(p.lexer.ScanString())
 		if err != nil {
 			return err
 		}
-		p.stack.current().appendWithOP(fn, expr)
+		p.stack.current().appendWithOP(op, p.opSpan, expr)
 	} else {
-		return fmt.Errorf("unexpect token <%s> before %d",
-			p.lexer.TokenSymbol(p.prevToken),
-			p.lexer.TokenIndex())
+		return p.unexpected()
 	}
 
 	return nil
 }
 
-func newConstExpr(value []byte) (Expr, error) {
-	if len(value) >= 2 && value[0] == quotation && value[len(value)-1] == quotation {
+func (p *parser) unexpected() error {
+	return fmt.Errorf("unexpect token <%s> before %s",
+		p.lexer.TokenSymbol(p.prevToken),
+		p.position().location())
+}
+
+// position returns the position of the current token in the input
+func (p *parser) position() Position {
+	return PositionOf(p.input, p.lexer.TokenIndex())
+}
+
+func (p *parser) span(start, end int) Span {
+	return Span{Start: start, End: end}
+}
+
+func (p *parser) newConstExpr(value []byte) (Expr, error) {
+	if v, ok := p.template.opts.consts[string(value)]; ok {
+		expr := newValueExpr(v)
+		expr.(sourceExpr).setSourceText(string(value))
+		return expr, nil
+	}
+
+	if len(p.template.opts.macros) > 0 && len(value) > 1 &&
+		value[0] == macroPrefix && isIdentifier(value[1:]) {
+		return nil, fmt.Errorf("macro %s not defined before %s", value, p.position().location())
+	}
+
+	if p.template.opts.bareVars {
+		name, varType, ok, err := p.template.bareVar(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w before %s", err, p.position().location())
+		}
+		if ok {
+			return p.newBareVarExpr(name, varType, value)
+		}
+	}
+
+	expr, err := newConstExpr(value, p.template.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.template.checkConst(expr); err != nil {
+		return nil, err
+	}
+
+	expr.(sourceExpr).setSourceText(string(value))
+	return expr, nil
+}
+
+// checkConst checks the array size and the regexp length of the const by the budget
+func (p *parserTemplate) checkConst(expr Expr) error {
+	if p.opts.budget == nil {
+		return nil
+	}
+
+	v, _ := expr.Exec(nil)
+	return p.opts.budget.checkValue(v)
+}
+
+// newBareVarExpr returns the var expr of the bare identifier before the current token
+func (p *parser) newBareVarExpr(name string, varType VarType, value []byte) (Expr, error) {
+	start := p.skipBlank(p.textStart)
+	span := p.span(start, start+len(value))
+
+	expr, err := p.template.newVarExpr(name, varType, string(value), span)
+	if err != nil {
+		return nil, err
+	}
+
+	if v, ok := expr.(*varExpr); ok && p.cb != nil {
+		p.cb(v.expr)
+	}
+	return expr, nil
+}
+
+// skipBlank returns the offset after the whitespaces and the comments starts at the pos
+func (p *parser) skipBlank(pos int) int {
+	var comments []Comment
+	if c, ok := p.lexer.(CommentLexer); ok {
+		comments = c.Comments()
+	}
+
+	for pos < len(p.input) {
+		if r, w := utf8.DecodeRune(p.input[pos:]); isWhitespace(r) {
+			pos += w
+			continue
+		}
+
+		skipped := false
+		for _, c := range comments {
+			if c.Span.Start == pos {
+				pos = c.Span.End
+				skipped = true
+				break
+			}
+		}
+		if !skipped {
+			break
+		}
+	}
+
+	return pos
+}
+
+func newConstExpr(value []byte, opts *options) (Expr, error) {
+	if isQuoted(value, quotation, quotation) || isQuoted(value, singleQuotation, singleQuotation) {
+		str, err := unquote(value[1 : len(value)-1])
+		if err != nil {
+			return nil, err
+		}
+		return &constString{
+			value: str,
+		}, nil
+	}
+
+	if isQuoted(value, backtick, backtick) {
 		return &constString{
-			value: string(revertConversion(value[1 : len(value)-1])),
+			value: string(value[1 : len(value)-1]),
 		}, nil
 	}
 
-	if len(value) >= 2 && value[0] == vertical && value[len(value)-1] == vertical {
-		pattern, err := regexp.Compile(string(revertConversion(value[1 : len(value)-1])))
+	if pattern, flags, ok := splitRegexp(value, opts.delimiter); ok {
+		if budget := opts.budget; budget != nil && budget.MaxRegexpLength > 0 &&
+			len(pattern) > budget.MaxRegexpLength {
+			return nil, &BudgetError{Kind: BudgetRegexpLength, Limit: budget.MaxRegexpLength}
+		}
+
+		return newRegexpExpr(pattern, flags, opts.matcher)
+	}
+
+	if opts.globs && isQuoted(value, hash, hash) {
+		pattern, err := CompileGlob(unescapeRegexp(value[1:len(value)-1], hash))
 		if err != nil {
 			return nil, err
 		}
-		return &constRegexp{
+		return &constGlob{
 			value: pattern,
 		}, nil
 	}
 
-	if len(value) >= 2 && value[0] == arrayLeft && value[len(value)-1] == arrayRight {
-		values := strings.Split(string(revertConversion(value[1:len(value)-1])), ",")
-		return &constArray{
-			values: values,
-		}, nil
+	if isQuoted(value, arrayLeft, arrayRight) {
+		values, err := splitArray(value[1 : len(value)-1])
+		if err != nil {
+			return nil, err
+		}
+		return newArrayExpr(values, opts.arraySet), nil
 	}
 
 	strValue := string(value)
+	if b, ok := boolLiterals[strValue]; ok && opts.typeCheck {
+		return &constValue{
+			value: b,
+		}, nil
+	}
+
 	int64Value, err := format.ParseStrInt64(strValue)
 	if err != nil {
 		return &constString{
@@ -432,60 +834,8 @@ func newConstExpr(value []byte) (Expr, error) {
 	}, nil
 }
 
-func revertConversion(src []byte) []byte {
-	var dst []byte
-	for _, v := range src {
-		if v == slashConversion {
-			dst = append(dst, slash)
-		} else if v == quotationConversion {
-			dst = append(dst, quotation)
-		} else if v == verticalConversion {
-			dst = append(dst, vertical)
-		} else if v == arrayLeftConversion {
-			dst = append(dst, arrayLeft)
-		} else if v == arrayRightConversion {
-			dst = append(dst, arrayRight)
-		} else {
-			dst = append(dst, v)
-		}
-	}
-
-	return dst
-}
-
-func conversion(src []byte) []byte {
-	// \" -> 0x00
-	// \\ -> \
-	// \/ -> 0x01
-	var dst []byte
-	for {
-		if len(src) == 0 {
-			return dst
-		} else if len(src) == 1 {
-			dst = append(dst, src...)
-			return dst
-		}
-
-		if src[0] != slash {
-			dst = append(dst, src[0])
-			src = src[1:]
-			continue
-		}
-
-		if src[0] == slash && src[1] == slash {
-			dst = append(dst, slashConversion)
-		} else if src[0] == slash && src[1] == quotation {
-			dst = append(dst, quotationConversion)
-		} else if src[0] == slash && src[1] == vertical {
-			dst = append(dst, verticalConversion)
-		} else if src[0] == slash && src[1] == arrayLeft {
-			dst = append(dst, arrayLeftConversion)
-		} else if src[0] == slash && src[1] == arrayRight {
-			dst = append(dst, arrayRightConversion)
-		} else {
-			dst = append(dst, src[0:2]...)
-		}
-
-		src = src[2:]
-	}
+func isLiteralToken(token int) bool {
+	return token == tokenLiteral ||
+		token == tokenSingleLiteral ||
+		token == tokenRawLiteral
 }
@@ -1,6 +1,7 @@
 package expr
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"testing"
@@ -172,6 +173,23 @@ func testMatch(left interface{}, right Expr, ctx interface{}) (interface{}, erro
 	return rightValue.(*regexp.Regexp).MatchString(left.(string)), nil
 }
 
+func testMatcher(left interface{}, right Expr, ctx interface{}) (interface{}, error) {
+	if _, ok := left.(string); !ok {
+		return nil, fmt.Errorf("expect string left value but %T", left)
+	}
+
+	rightValue, err := right.Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := rightValue.(Matcher); !ok {
+		return nil, fmt.Errorf("expect matcher right value but %T", rightValue)
+	}
+
+	return rightValue.(Matcher).MatchString(left.(string)), nil
+}
+
 func TestParser(t *testing.T) {
 	p := NewParser(nil,
 		WithOp("+", testAdd),
@@ -216,6 +234,45 @@ func TestParserWithVarAndLiteral(t *testing.T) {
 	assert.Equal(t, true, value, "TestParser failed")
 }
 
+func TestParserWithStringLiterals(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("==", testStrEqual),
+		WithOp("&&", testAndLogic),
+		WithVarType("str:", Str))
+
+	ctx := make(map[string]string)
+	ctx["a"] = "a\n\"b\"\t中"
+	ctx["b"] = "it's && \\n"
+	ctx["c"] = "\x00|"
+
+	expr, err := p.Parse([]byte(`({str:a} == "a\n\"b\"\t\u4e2d") && ({str:b} == 'it\'s && \\n') && ({str:b} == `+"`it's && \\n`"+`) && ({str:c} == "\x00|")`), nil)
+	assert.NoError(t, err, "TestParserWithStringLiterals failed")
+	value, err := expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithStringLiterals failed")
+	assert.Equal(t, true, value, "TestParserWithStringLiterals failed")
+
+	expr, err = p.Parse([]byte("{str:c} == \"\x00|\""), nil)
+	assert.NoError(t, err, "TestParserWithStringLiterals failed")
+	value, err = expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithStringLiterals failed")
+	assert.Equal(t, true, value, "TestParserWithStringLiterals failed")
+
+	_, err = p.Parse([]byte(`{str:a} == "\q"`), nil)
+	assert.Error(t, err, "TestParserWithStringLiterals failed")
+
+	_, err = p.Parse([]byte(`{str:a} == 'abc`), nil)
+	assert.Error(t, err, "TestParserWithStringLiterals failed")
+
+	// the quotes in the text don't start the literals
+	ctx["d"] = "O'Brien"
+	ctx["e"] = "a`b"
+	expr, err = p.Parse([]byte("({str:d} == O'Brien) && ({str:e} == a`b)"), nil)
+	assert.NoError(t, err, "TestParserWithStringLiterals failed")
+	value, err = expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithStringLiterals failed")
+	assert.Equal(t, true, value, "TestParserWithStringLiterals failed")
+}
+
 func TestParserWithVar(t *testing.T) {
 	p := NewParser(testVarFactory,
 		WithOp("+", testAdd),
@@ -276,6 +333,88 @@ func TestParserRegexpWithVar(t *testing.T) {
 	assert.Equal(t, true, value, "TestParserRegexpWithVar failed")
 }
 
+func TestParserRegexpWithFlags(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("~", testMatch),
+		WithOp("&&", testAndLogic),
+		WithVarType("str:", Str),
+		WithRegexpDelimiter('/'))
+
+	ctx := make(map[string]string)
+	ctx["1"] = "A|B\nc/d"
+
+	expr, err := p.Parse([]byte(`({str:1} ~ /^a|b$/im) && ({str:1} ~ /b.c\/d/si) && ({str:1} ~ /\|/)`), nil)
+	assert.NoError(t, err, "TestParserRegexpWithFlags failed")
+	value, err := expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserRegexpWithFlags failed")
+	assert.Equal(t, true, value, "TestParserRegexpWithFlags failed")
+
+	expr, err = p.Parse([]byte(`{str:1} ~ /^a/`), nil)
+	assert.NoError(t, err, "TestParserRegexpWithFlags failed")
+	value, err = expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserRegexpWithFlags failed")
+	assert.Equal(t, false, value, "TestParserRegexpWithFlags failed")
+
+	// the text like the path is not a regexp literal
+	p = NewParser(testVarFactory,
+		WithOp("===", testStrEqual),
+		WithVarType("str:", Str),
+		WithRegexpDelimiter('/'))
+	expr, err = p.Parse([]byte(`{str:1} === /api/users`), nil)
+	assert.NoError(t, err, "TestParserRegexpWithFlags failed")
+	value, err = expr.Exec(map[string]string{"1": "/api/users"})
+	assert.NoError(t, err, "TestParserRegexpWithFlags failed")
+	assert.Equal(t, true, value, "TestParserRegexpWithFlags failed")
+
+	// `//` is the empty regexp with the comments
+	p = NewParser(testVarFactory,
+		WithOp("~", testMatch),
+		WithVarType("str:", Str),
+		WithRegexpDelimiter('/'),
+		WithComments())
+	expr, err = p.Parse([]byte(`{str:1} ~ // /* any */`), nil)
+	assert.NoError(t, err, "TestParserRegexpWithFlags failed")
+	value, err = expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserRegexpWithFlags failed")
+	assert.Equal(t, true, value, "TestParserRegexpWithFlags failed")
+	assert.Equal(t, 1, len(Comments(expr)), "TestParserRegexpWithFlags failed")
+}
+
+func TestParserRegexpWithMatcher(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("~", testMatcher),
+		WithVarType("str:", Str),
+		WithMatcher(CompileWildcard))
+
+	ctx := make(map[string]string)
+	ctx["1"] = "Hello.World"
+
+	expr, err := p.Parse([]byte(`{str:1} ~ |hello?w*|i`), nil)
+	assert.NoError(t, err, "TestParserRegexpWithMatcher failed")
+	value, err := expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserRegexpWithMatcher failed")
+	assert.Equal(t, true, value, "TestParserRegexpWithMatcher failed")
+
+	data, err := EncodeExpr(expr)
+	assert.NoError(t, err, "TestParserRegexpWithMatcher failed")
+	expr, err = DecodeExpr(p, data)
+	assert.NoError(t, err, "TestParserRegexpWithMatcher failed")
+	value, err = expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserRegexpWithMatcher failed")
+	assert.Equal(t, true, value, "TestParserRegexpWithMatcher failed")
+
+	data, err = MarshalExpr(expr)
+	assert.NoError(t, err, "TestParserRegexpWithMatcher failed")
+	expr, err = UnmarshalExpr(p, data)
+	assert.NoError(t, err, "TestParserRegexpWithMatcher failed")
+	value, err = expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserRegexpWithMatcher failed")
+	assert.Equal(t, true, value, "TestParserRegexpWithMatcher failed")
+
+	_, err = p.Parse([]byte(`{str:1} ~ |hello|m`), nil)
+	assert.Error(t, err, "TestParserRegexpWithMatcher failed")
+}
+
 func TestParserArrayWithVar(t *testing.T) {
 	p := NewParser(testVarFactory,
 		WithOp("in", testStrIn),
@@ -298,34 +437,158 @@ func TestParserArrayWithVar(t *testing.T) {
 	assert.Equal(t, false, value, "TestParserArrayWithVar failed")
 }
 
-func TestConversionAndRevert(t *testing.T) {
-	value := conversion([]byte(`"`))
-	assert.Equal(t, []byte(`"`), value, "TestConversion failed")
-	assert.Equal(t, []byte(`"`), revertConversion(value), "TestConversion failed")
+func TestParserWithKeywordOps(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("in", testStrIn),
+		WithOp("and", testAndLogic),
+		WithVarType("str:", Str))
+
+	ctx := make(map[string]string)
+	ctx["index"] = "inner"
+
+	expr, err := p.Parse([]byte("({str:index} in [inner,index]) and (domain in [main,domain])"), nil)
+	assert.NoError(t, err, "TestParserWithKeywordOps failed")
+	value, err := expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithKeywordOps failed")
+	assert.Equal(t, true, value, "TestParserWithKeywordOps failed")
+	assert.Equal(t, []VarRef{{Name: "index", Type: Str, Span: Span{Start: 1, End: 12}}}, Variables(expr), "TestParserWithKeywordOps failed")
+}
+
+func TestParserWithConst(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("+", testAdd),
+		WithOp("==", testEqual),
+		WithVarType("num:", Num),
+		WithConst("MAX_RETRIES", int64(3)),
+		WithConst("MIN_RETRIES", "1"),
+		WithConst("RETRIES", uint16(2)),
+		WithConst("NAME", "abc"))
+
+	ctx := make(map[string]string)
+	ctx["1"] = "2"
+
+	var vars []Expr
+	expr, err := p.Parse([]byte("{num:1}+1=={MAX_RETRIES}"), func(v Expr) { vars = append(vars, v) })
+	assert.NoError(t, err, "TestParserWithConst failed")
+	assert.Equal(t, 1, len(vars), "TestParserWithConst failed")
+	value, err := expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithConst failed")
+	assert.Equal(t, true, value, "TestParserWithConst failed")
+
+	expr, err = p.Parse([]byte("{num:1}+1==MAX_RETRIES"), nil)
+	assert.NoError(t, err, "TestParserWithConst failed")
+	value, err = expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithConst failed")
+	assert.Equal(t, true, value, "TestParserWithConst failed")
+
+	expr, err = p.Parse([]byte("{num:MIN_RETRIES}+1=={RETRIES}"), nil)
+	assert.NoError(t, err, "TestParserWithConst failed")
+	value, err = expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithConst failed")
+	assert.Equal(t, true, value, "TestParserWithConst failed")
+
+	_, err = p.Parse([]byte("{num:NAME}==1"), nil)
+	assert.Error(t, err, "TestParserWithConst failed")
+
+	// the bare bools are the strings without WithTypeCheck
+	p = NewParser(testVarFactory,
+		WithOp("==", testStrEqual),
+		WithVarType("str:", Str))
+	expr, err = p.Parse([]byte("{str:flag} == true"), nil)
+	assert.NoError(t, err, "TestParserWithConst failed")
+	value, err = expr.Exec(map[string]string{"flag": "true"})
+	assert.NoError(t, err, "TestParserWithConst failed")
+	assert.Equal(t, true, value, "TestParserWithConst failed")
+}
+
+func TestParserWithUnicode(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("==", testStrEqual),
+		WithVarType("str:", Str))
+
+	ctx := make(map[string]string)
+	ctx["城市"] = "北京"
+
+	expr, err := p.Parse([]byte("{str:城市}\u3000==\u3000\"北京\""), nil)
+	assert.NoError(t, err, "TestParserWithUnicode failed")
+	value, err := expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithUnicode failed")
+	assert.Equal(t, true, value, "TestParserWithUnicode failed")
+
+	_, err = p.Parse([]byte("\"北京\"\n==\n{str:城市}{str:a}"), nil)
+	assert.Error(t, err, "TestParserWithUnicode failed")
+	assert.Equal(t, "unexpect token <}> before 24 (3:9)", err.Error(), "TestParserWithUnicode failed")
+
+	_, err = p.Parse([]byte("\"北京\"==a)"), nil)
+	assert.Error(t, err, "TestParserWithUnicode failed")
+}
+
+func TestParserWithComments(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("+", testAdd),
+		WithOp("==", testEqual),
+		WithOp("===", testStrEqual),
+		WithOp("&&", testAndLogic),
+		WithVarType("num:", Num),
+		WithVarType("str:", Str),
+		WithComments())
 
-	value = conversion([]byte(`""`))
-	assert.Equal(t, []byte(`""`), value, "TestConversion failed")
-	assert.Equal(t, []byte(`""`), revertConversion(value), "TestConversion failed")
+	ctx := make(map[string]string)
+	ctx["a"] = "1"
+	ctx["b"] = "/* not a comment */"
+
+	input := `// adult check
+({num:a} /* age */ + 1 == 2) && ({str:b} === "/* not a comment */") &&
+(/* name */ a // the name
+=== a)`
+	expr, err := p.Parse([]byte(input), nil)
+	assert.NoError(t, err, "TestParserWithComments failed")
+	value, err := expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithComments failed")
+	assert.Equal(t, true, value, "TestParserWithComments failed")
+
+	comments := Comments(expr)
+	assert.Equal(t, 4, len(comments), "TestParserWithComments failed")
+	assert.Equal(t, "// adult check", comments[0].Text, "TestParserWithComments failed")
+	assert.Equal(t, "/* age */", comments[1].Text, "TestParserWithComments failed")
+	assert.Equal(t, "/* age */", input[comments[1].Span.Start:comments[1].Span.End], "TestParserWithComments failed")
+	assert.Equal(t, "/* name */", comments[2].Text, "TestParserWithComments failed")
+	assert.Equal(t, "// the name", comments[3].Text, "TestParserWithComments failed")
+
+	_, err = p.Parse([]byte("{num:a} == 1 /* unclosed"), nil)
+	assert.Error(t, err, "TestParserWithComments failed")
+	assert.Equal(t, "missing */ after 13 (1:14)", err.Error(), "TestParserWithComments failed")
+
+	// the comments are not recognized without WithComments
+	p = NewParser(testVarFactory,
+		WithOp("===", testStrEqual),
+		WithVarType("str:", Str))
+	expr, err = p.Parse([]byte("{str:a} === http://x.com/a"), nil)
+	assert.NoError(t, err, "TestParserWithComments failed")
+	value, err = expr.Exec(map[string]string{"a": "http://x.com/a"})
+	assert.NoError(t, err, "TestParserWithComments failed")
+	assert.Equal(t, true, value, "TestParserWithComments failed")
+	assert.Empty(t, Comments(expr), "TestParserWithComments failed")
+}
 
-	value = conversion([]byte(`\"`))
-	assert.Equal(t, []byte{quotationConversion}, value, "TestConversion failed")
-	assert.Equal(t, []byte(`"`), revertConversion(value), "TestConversion failed")
+func TestParserWithLimits(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("+", testAdd),
+		WithMaxInputLength(17),
+		WithMaxDepth(2),
+		WithMaxTokens(7))
 
-	value = conversion([]byte(`b\"a`))
-	assert.Equal(t, []byte{'b', quotationConversion, 'a'}, value, "TestConversion failed")
-	assert.Equal(t, []byte(`b"a`), revertConversion(value), "TestConversion failed")
+	_, err := p.Parse([]byte("1+(2+(3+4))"), nil)
+	assert.NoError(t, err, "TestParserWithLimits failed")
 
-	value = conversion([]byte(`\\`))
-	assert.Equal(t, []byte{slashConversion}, value, "TestConversion failed")
-	assert.Equal(t, []byte(`\`), revertConversion(value), "TestConversion failed")
+	_, err = p.Parse([]byte("1+2+3+4+5+6+7+8+90"), nil)
+	assert.True(t, errors.Is(err, ErrMaxInputLength), "TestParserWithLimits failed")
 
-	value = conversion([]byte(`\\\"`))
-	assert.Equal(t, []byte{slashConversion, quotationConversion}, value, "TestConversion failed")
-	assert.Equal(t, []byte(`\"`), revertConversion(value), "TestConversion failed")
+	_, err = p.Parse([]byte("((((1+2))))"), nil)
+	assert.True(t, errors.Is(err, ErrMaxDepth), "TestParserWithLimits failed")
 
-	value = conversion([]byte(`\\\"\`))
-	assert.Equal(t, []byte{slashConversion, quotationConversion, '\\'}, value, "TestConversion failed")
-	assert.Equal(t, []byte(`\"\`), revertConversion(value), "TestConversion failed")
+	_, err = p.Parse([]byte("1+2+3+4+5+6+7+8+9"), nil)
+	assert.True(t, errors.Is(err, ErrMaxTokens), "TestParserWithLimits failed")
 }
 
 type testMapBasedVarExpr struct {
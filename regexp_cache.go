@@ -0,0 +1,84 @@
+package expr
+
+import (
+	"regexp"
+	"sync"
+)
+
+const (
+	defaultRegexpCacheSize = 1024
+)
+
+var (
+	regexps = newRegexpCache(defaultRegexpCacheSize)
+)
+
+// SetRegexpCacheSize set the max number of the compiled regexps shared by ValueByType and
+// the regexp literals, default is 1024, the cache is disabled if the size is 0. The cache
+// is process-wide, it's shared by all the parsers and the exprs.
+func SetRegexpCacheSize(size int) {
+	regexps.resize(size)
+}
+
+// RegexpCacheStats returns the stats of the process-wide compiled regexps cache
+func RegexpCacheStats() CacheStats {
+	return regexps.getStats()
+}
+
+// regexpCache a bounded lru cache of the compiled regexps keyed by the pattern,
+// it's safe for concurrent use
+type regexpCache struct {
+	mu    sync.Mutex
+	cache *lru
+	stats CacheStats
+}
+
+func newRegexpCache(size int) *regexpCache {
+	return &regexpCache{
+		cache: newLRU(size),
+	}
+}
+
+// compile returns the cached regexp of the pattern, or compiles and caches the regexp,
+// the invalid patterns are not cached
+func (c *regexpCache) compile(pattern string) (*regexp.Regexp, error) {
+	c.mu.Lock()
+	value, ok := c.cache.get(pattern)
+	if ok {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+	c.mu.Unlock()
+
+	if ok {
+		return value.(*regexp.Regexp), nil
+	}
+
+	r, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.cache.size > 0 {
+		c.stats.Evictions += uint64(c.cache.add(pattern, r))
+	}
+	c.mu.Unlock()
+	return r, nil
+}
+
+func (c *regexpCache) resize(size int) {
+	c.mu.Lock()
+	c.stats.Evictions += uint64(c.cache.resize(size))
+	c.mu.Unlock()
+}
+
+func (c *regexpCache) getStats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = c.cache.len()
+	return stats
+}
@@ -0,0 +1,70 @@
+package expr
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegexpCache(t *testing.T) {
+	c := newRegexpCache(2)
+
+	a, err := c.compile("^a$")
+	assert.NoError(t, err, "TestRegexpCache failed")
+	b, err := c.compile("^a$")
+	assert.NoError(t, err, "TestRegexpCache failed")
+	assert.True(t, a == b, "TestRegexpCache failed")
+
+	_, err = c.compile("^b$")
+	assert.NoError(t, err, "TestRegexpCache failed")
+	_, err = c.compile("^c$")
+	assert.NoError(t, err, "TestRegexpCache failed")
+	_, err = c.compile("(")
+	assert.Error(t, err, "TestRegexpCache failed")
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 4, Evictions: 1, Size: 2}, c.getStats(), "TestRegexpCache failed")
+
+	c.resize(0)
+	_, err = c.compile("^a$")
+	assert.NoError(t, err, "TestRegexpCache failed")
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 5, Evictions: 3, Size: 0}, c.getStats(), "TestRegexpCache failed")
+}
+
+func TestRegexpCacheWithValueByType(t *testing.T) {
+	defer SetRegexpCacheSize(defaultRegexpCacheSize)
+	SetRegexpCacheSize(0)
+	SetRegexpCacheSize(defaultRegexpCacheSize)
+
+	before := RegexpCacheStats()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				value, err := ValueByType([]byte("^cached-[0-9]+$"), Regexp)
+				assert.NoError(t, err, "TestRegexpCacheWithValueByType failed")
+				assert.True(t, value.(Matcher).MatchString("cached-1"), "TestRegexpCacheWithValueByType failed")
+			}
+		}()
+	}
+	wg.Wait()
+
+	after := RegexpCacheStats()
+	assert.Equal(t, uint64(800), after.Hits+after.Misses-before.Hits-before.Misses, "TestRegexpCacheWithValueByType failed")
+	assert.True(t, after.Hits-before.Hits >= 792, "TestRegexpCacheWithValueByType failed")
+	assert.Equal(t, 1, after.Size, "TestRegexpCacheWithValueByType failed")
+}
+
+func TestRegexpCacheWithReusedBuffer(t *testing.T) {
+	buf := []byte("^reused-a$")
+	_, err := ValueByType(buf, Regexp)
+	assert.NoError(t, err, "TestRegexpCacheWithReusedBuffer failed")
+
+	// the cached pattern doesn't change with the buffer of the caller
+	copy(buf, "^reused-b$")
+	value, err := ValueByType([]byte("^reused-a$"), Regexp)
+	assert.NoError(t, err, "TestRegexpCacheWithReusedBuffer failed")
+	assert.Equal(t, "^reused-a$", value.(Matcher).String(), "TestRegexpCacheWithReusedBuffer failed")
+	assert.True(t, value.(Matcher).MatchString("reused-a"), "TestRegexpCacheWithReusedBuffer failed")
+}
@@ -21,6 +21,9 @@ func initDefaultValues() {
 	defaultValues[Str] = ""
 	defaultValues[Num] = int64(0)
 	defaultValues[Regexp] = regexp.MustCompile(".*")
+	defaultValues[Bool] = false
+	defaultValues[Array] = []string{}
+	defaultValues[Glob] = MustCompileGlob(globAny)
 }
 
 func defaultValue(varType VarType) interface{} {
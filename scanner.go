@@ -1,5 +1,12 @@
 package expr
 
+import (
+	"bytes"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
 type scanner struct {
 	len   int
 	input []byte
@@ -8,7 +15,19 @@ type scanner struct {
 	bp         int
 	sp         int
 	scanOffset int
-	ch         byte
+	ch         rune
+	width      int
+
+	// raw the token closes the current literal, regexp or array, the
+	// comments and the other tokens are not recognized in them
+	raw int
+	// tokenEnd the byte offset after the last token or comment
+	tokenEnd int
+	// scanComments the comments are recognized
+	scanComments bool
+	comments     []Comment
+	pending      int
+	err          error
 
 	st *symbolTable
 }
@@ -20,12 +39,13 @@ func NewScanner(input []byte) Lexer {
 		input: input,
 		bp:    -1,
 		sp:    0,
+		width: 1,
 		st: &symbolTable{
 			tokens: make(map[int]string),
 		},
 	}
 
-	scan.Next()
+	scan.NextRune()
 
 	return scan
 }
@@ -34,28 +54,34 @@ func (scan *scanner) AddSymbol(symbol []byte, token int) {
 	scan.st.addSymbol(symbol, token)
 }
 
+// Next moves to the next byte, the current rune is the rune starts at the byte
 func (scan *scanner) Next() byte {
+	if scan.bp < scan.len {
+		scan.width = 1
+	}
+	scan.NextRune()
+	return scan.Current()
+}
+
+func (scan *scanner) NextRune() rune {
 	if scan.bp >= scan.len {
-		scan.ch = EOI
+		scan.ch = rune(EOI)
 		return scan.ch
 	}
 
-	for {
-		scan.bp++
-
-		if scan.bp < scan.len {
-			scan.ch = scan.input[scan.bp]
-		} else {
-			scan.ch = EOI
-		}
-
-		return scan.ch
+	scan.bp += scan.width
+	if scan.bp < scan.len {
+		scan.ch, scan.width = utf8.DecodeRune(scan.input[scan.bp:])
+	} else {
+		scan.ch, scan.width = rune(EOI), 1
 	}
+
+	return scan.ch
 }
 
 func (scan *scanner) NextToken() {
 	for {
-		if scan.ch == EOI {
+		if scan.ch == rune(EOI) {
 			scan.token = TokenEOI
 			scan.scanOffset = 0
 			return
@@ -63,19 +89,41 @@ func (scan *scanner) NextToken() {
 
 		scan.skipWhitespaces()
 
-		token := scan.findLongestToken()
+		var token int
+		if scan.raw != 0 {
+			token = scan.findRawEnd()
+		} else if scan.skipComment() {
+			continue
+		} else {
+			token = scan.findLongestToken()
+			if (token == tokenSingleLiteral || token == tokenRawLiteral) && !scan.atTokenStart() {
+				// the quote in the text like `O'Brien`
+				token = 0
+			}
+		}
+
 		if token > 0 {
+			scan.tokenEnd = scan.bp + 1
+			scan.updateRaw(token)
 			scan.token = token
 			scan.scanOffset = len(scan.TokenSymbol(token))
-			scan.Next()
+			scan.NextRune()
 			return
 		}
 
-		scan.Next()
+		scan.NextRune()
 	}
 }
 
 func (scan *scanner) Current() byte {
+	if scan.bp < 0 || scan.bp >= scan.len {
+		return EOI
+	}
+
+	return scan.input[scan.bp]
+}
+
+func (scan *scanner) CurrentRune() rune {
 	return scan.ch
 }
 
@@ -91,36 +139,134 @@ func (scan *scanner) TokenSymbol(token int) string {
 	return scan.st.tokens[token]
 }
 
-func (scan *scanner) ScanString() []byte {
-	value := scan.input[scan.sp : scan.bp-scan.scanOffset]
-	scan.sp = scan.bp
+func (scan *scanner) ScanComments() {
+	scan.scanComments = true
+}
 
-	found := false
-	for i, ch := range value {
-		if !isWhitespace(ch) {
-			value = value[i:]
-			found = true
-			break
+func (scan *scanner) Comments() []Comment {
+	return scan.comments
+}
+
+func (scan *scanner) Err() error {
+	return scan.err
+}
+
+func (scan *scanner) ScanString() []byte {
+	end := scan.bp - scan.scanOffset
+	value := scan.input[scan.sp:end]
+
+	// the comments are replaced with a space
+	if scan.pending < len(scan.comments) && scan.comments[scan.pending].Span.Start < end {
+		var buf []byte
+		pos := scan.sp
+		for ; scan.pending < len(scan.comments); scan.pending++ {
+			c := scan.comments[scan.pending]
+			if c.Span.Start >= end {
+				break
+			}
+
+			if c.Span.Start >= pos {
+				buf = append(buf, scan.input[pos:c.Span.Start]...)
+				buf = append(buf, ' ')
+				pos = c.Span.End
+			}
 		}
+		value = append(buf, scan.input[pos:end]...)
 	}
+	scan.sp = scan.bp
 
-	if !found {
+	value = bytes.TrimFunc(value, isWhitespace)
+	if len(value) == 0 {
 		return nil
 	}
 
-	for i := len(value) - 1; i >= 0; i-- {
-		if !isWhitespace(value[i]) {
-			value = value[0 : i+1]
-			found = true
-			break
-		}
-	}
-
 	return value
 }
 
 func (scan *scanner) SkipString() {
 	scan.sp = scan.bp
+	for scan.pending < len(scan.comments) && scan.comments[scan.pending].Span.Start < scan.sp {
+		scan.pending++
+	}
+}
+
+// skipComment skips the `//` line comment or the `/* */` block comment
+func (scan *scanner) skipComment() bool {
+	if !scan.scanComments || scan.ch != '/' || scan.bp+1 >= scan.len {
+		return false
+	}
+
+	start := scan.bp
+	end := scan.len
+	switch scan.input[start+1] {
+	case '/':
+		if scan.TokenSymbol(tokenRegexp) == "/" {
+			// `//` is the empty regexp literal with the `/` delimiter
+			return false
+		}
+		if idx := bytes.IndexByte(scan.input[start:], '\n'); idx >= 0 {
+			end = start + idx
+		}
+	case '*':
+		if idx := bytes.Index(scan.input[start+2:], symbolBlockCommentEnd); idx >= 0 {
+			end = start + 2 + idx + len(symbolBlockCommentEnd)
+		} else if scan.err == nil {
+			scan.err = fmt.Errorf("missing %s after %s", symbolBlockCommentEnd,
+				PositionOf(scan.input, start).location())
+		}
+	default:
+		return false
+	}
+
+	scan.comments = append(scan.comments, Comment{
+		Text: string(scan.input[start:end]),
+		Span: Span{Start: start, End: end},
+	})
+	scan.bp = end - 1
+	scan.width = 1
+	scan.tokenEnd = end
+	scan.NextRune()
+	return true
+}
+
+// atTokenStart returns true if only the whitespaces are between the last token and the current rune
+func (scan *scanner) atTokenStart() bool {
+	return len(bytes.TrimLeftFunc(scan.input[scan.tokenEnd:scan.bp], isWhitespace)) == 0
+}
+
+func (scan *scanner) updateRaw(token int) {
+	if scan.raw != 0 {
+		if token == scan.raw {
+			scan.raw = 0
+		}
+		return
+	}
+
+	switch token {
+	case tokenLiteral, tokenSingleLiteral, tokenRawLiteral, tokenRegexp, tokenGlob:
+		scan.raw = token
+	case tokenArrayStart:
+		scan.raw = tokenArrayEnd
+	}
+}
+
+// findRawEnd returns the raw token if the current rune starts it, the escaped
+// rune is skipped except in the raw string literals
+func (scan *scanner) findRawEnd() int {
+	if scan.ch == slash && scan.raw != tokenRawLiteral {
+		scan.NextRune()
+		return 0
+	}
+
+	symbol := scan.TokenSymbol(scan.raw)
+	if symbol == "" || !bytes.HasPrefix(scan.input[scan.bp:], []byte(symbol)) {
+		return 0
+	}
+
+	scan.bp += len(symbol) - 1
+	scan.ch = rune(scan.input[scan.bp])
+	scan.width = 1
+	return scan.raw
 }
 
 func (scan *scanner) findLongestToken() int {
@@ -129,7 +275,7 @@ func (scan *scanner) findLongestToken() int {
 
 	for i := scan.bp; i < scan.len; i++ {
 		token, maybe := scan.st.findToken(scan.input[scan.bp : i+1])
-		if token > 0 {
+		if token > 0 && scan.atWordBoundary(scan.bp, i+1) {
 			last = token
 			pos = i
 		}
@@ -140,17 +286,38 @@ func (scan *scanner) findLongestToken() int {
 	}
 
 	if last > 0 {
+		// the next rune starts after the last byte of the token
 		scan.bp = pos
-		scan.ch = scan.input[pos]
+		scan.ch = rune(scan.input[pos])
+		scan.width = 1
 	}
 
 	return last
 }
 
+// atWordBoundary returns false if the symbol in [start, end) starts or ends with a ASCII
+// word char and joins the word char next to it, so that the keyword ops like `in` don't
+// split the words like `index`
+func (scan *scanner) atWordBoundary(start, end int) bool {
+	if isWordByte(scan.input[start]) && start > 0 {
+		if r, _ := utf8.DecodeLastRune(scan.input[:start]); isWordRune(r) {
+			return false
+		}
+	}
+
+	if isWordByte(scan.input[end-1]) && end < scan.len {
+		if r, _ := utf8.DecodeRune(scan.input[end:]); isWordRune(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (scan *scanner) skipWhitespaces() {
 	for {
 		if isWhitespace(scan.ch) {
-			scan.Next()
+			scan.NextRune()
 			continue
 		}
 
@@ -158,6 +325,14 @@ func (scan *scanner) skipWhitespaces() {
 	}
 }
 
-func isWhitespace(ch byte) bool {
-	return ch == ' ' || ch == '\n' || ch == '\r' || ch == '\t' || ch == '\f' || ch == '\b'
+func isWordByte(c byte) bool {
+	return c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isWhitespace(ch rune) bool {
+	return unicode.IsSpace(ch) || ch == '\b'
 }
@@ -46,3 +46,126 @@ func TestNextTokenWithGap(t *testing.T) {
 	assert.Equal(t, 4, scan.Token(), "TestNextToken failed")
 	assert.Equal(t, "", string(scan.ScanString()), "TestNextToken failed")
 }
+
+func TestNextTokenWithUnicode(t *testing.T) {
+	scan := NewScanner([]byte("\u3000中文\u3000&&\u00a0包含 文字 "))
+	scan.AddSymbol([]byte("&&"), 2)
+	scan.AddSymbol([]byte("包含"), 3)
+
+	scan.NextToken()
+	assert.Equal(t, 2, scan.Token(), "TestNextTokenWithUnicode failed")
+	assert.Equal(t, "中文", string(scan.ScanString()), "TestNextTokenWithUnicode failed")
+
+	scan.NextToken()
+	assert.Equal(t, 3, scan.Token(), "TestNextTokenWithUnicode failed")
+	assert.Equal(t, "", string(scan.ScanString()), "TestNextTokenWithUnicode failed")
+
+	scan.NextToken()
+	assert.Equal(t, TokenEOI, scan.Token(), "TestNextTokenWithUnicode failed")
+	assert.Equal(t, "文字", string(scan.ScanString()), "TestNextTokenWithUnicode failed")
+}
+
+func TestNextRune(t *testing.T) {
+	scan := NewScanner([]byte("中a"))
+	assert.Equal(t, byte(0xe4), scan.Current(), "TestNextRune failed")
+
+	runes := scan.(RuneLexer)
+	assert.Equal(t, '中', runes.CurrentRune(), "TestNextRune failed")
+	assert.Equal(t, 'a', runes.NextRune(), "TestNextRune failed")
+	assert.Equal(t, rune(EOI), runes.NextRune(), "TestNextRune failed")
+
+	scan = NewScanner([]byte("中a"))
+	assert.Equal(t, byte(0xb8), scan.Next(), "TestNextRune failed")
+	assert.Equal(t, byte(0xad), scan.Next(), "TestNextRune failed")
+	assert.Equal(t, byte('a'), scan.Next(), "TestNextRune failed")
+	assert.Equal(t, 'a', scan.(RuneLexer).CurrentRune(), "TestNextRune failed")
+	assert.Equal(t, EOI, scan.Next(), "TestNextRune failed")
+}
+
+func TestPositionOf(t *testing.T) {
+	input := []byte("中文\n a中")
+	assert.Equal(t, Position{Offset: 0, Line: 1, Column: 1}, PositionOf(input, 0), "TestPositionOf failed")
+	assert.Equal(t, Position{Offset: 3, Line: 1, Column: 2}, PositionOf(input, 3), "TestPositionOf failed")
+	assert.Equal(t, Position{Offset: 7, Line: 2, Column: 1}, PositionOf(input, 7), "TestPositionOf failed")
+	assert.Equal(t, Position{Offset: 9, Line: 2, Column: 3}, PositionOf(input, 9), "TestPositionOf failed")
+	assert.Equal(t, "2:4", PositionOf(input, 12).String(), "TestPositionOf failed")
+}
+
+func TestNextTokenWithComments(t *testing.T) {
+	scan := NewScanner([]byte("1 /* a && b */ && \"//\" // c && d\n&& 2"))
+	scan.(CommentLexer).ScanComments()
+	scan.AddSymbol([]byte("&&"), 2)
+	scan.AddSymbol([]byte("\""), tokenLiteral)
+
+	scan.NextToken()
+	assert.Equal(t, 2, scan.Token(), "TestNextTokenWithComments failed")
+	assert.Equal(t, "1", string(scan.ScanString()), "TestNextTokenWithComments failed")
+
+	scan.NextToken()
+	assert.Equal(t, tokenLiteral, scan.Token(), "TestNextTokenWithComments failed")
+	scan.NextToken()
+	assert.Equal(t, tokenLiteral, scan.Token(), "TestNextTokenWithComments failed")
+
+	scan.NextToken()
+	assert.Equal(t, 2, scan.Token(), "TestNextTokenWithComments failed")
+	assert.Equal(t, `"//"`, string(scan.ScanString()), "TestNextTokenWithComments failed")
+
+	scan.NextToken()
+	assert.Equal(t, TokenEOI, scan.Token(), "TestNextTokenWithComments failed")
+	assert.Equal(t, "2", string(scan.ScanString()), "TestNextTokenWithComments failed")
+
+	comments := scan.(CommentLexer).Comments()
+	assert.Equal(t, 2, len(comments), "TestNextTokenWithComments failed")
+	assert.Equal(t, "/* a && b */", comments[0].Text, "TestNextTokenWithComments failed")
+	assert.Equal(t, Span{Start: 2, End: 14}, comments[0].Span, "TestNextTokenWithComments failed")
+	assert.Equal(t, "// c && d", comments[1].Text, "TestNextTokenWithComments failed")
+	assert.NoError(t, scan.(CommentLexer).Err(), "TestNextTokenWithComments failed")
+
+	// the comments are not recognized by default
+	scan = NewScanner([]byte("a//b && c"))
+	scan.AddSymbol([]byte("&&"), 2)
+	scan.NextToken()
+	assert.Equal(t, 2, scan.Token(), "TestNextTokenWithComments failed")
+	assert.Equal(t, "a//b", string(scan.ScanString()), "TestNextTokenWithComments failed")
+
+	scan = NewScanner([]byte("a/*b && c"))
+	scan.(CommentLexer).ScanComments()
+	scan.AddSymbol([]byte("&&"), 2)
+	scan.NextToken()
+	assert.Equal(t, TokenEOI, scan.Token(), "TestNextTokenWithComments failed")
+	assert.Error(t, scan.(CommentLexer).Err(), "TestNextTokenWithComments failed")
+}
+
+func TestNextTokenWithLiterals(t *testing.T) {
+	scan := NewScanner([]byte("\"a\\\"&&\" && `b\\` && '\\''"))
+	scan.AddSymbol([]byte("&&"), 2)
+	scan.AddSymbol([]byte("\""), tokenLiteral)
+	scan.AddSymbol([]byte("'"), tokenSingleLiteral)
+	scan.AddSymbol([]byte("`"), tokenRawLiteral)
+
+	var tokens []int
+	for scan.NextToken(); scan.Token() != TokenEOI; scan.NextToken() {
+		tokens = append(tokens, scan.Token())
+	}
+	assert.Equal(t, []int{tokenLiteral, tokenLiteral, 2, tokenRawLiteral, tokenRawLiteral, 2,
+		tokenSingleLiteral, tokenSingleLiteral}, tokens, "TestNextTokenWithLiterals failed")
+}
+
+func TestNextTokenWithWordBoundary(t *testing.T) {
+	scan := NewScanner([]byte("index in inner and 1and 中in+in_a notin not"))
+	scan.AddSymbol([]byte("in"), tokenCustom+1)
+	scan.AddSymbol([]byte("and"), tokenCustom+2)
+	scan.AddSymbol([]byte("+in"), tokenCustom+3)
+	scan.AddSymbol([]byte("not"), tokenCustom+4)
+	scan.AddSymbol([]byte("+"), tokenCustom+5)
+
+	var tokens []int
+	var values []string
+	for scan.NextToken(); scan.Token() != TokenEOI; scan.NextToken() {
+		tokens = append(tokens, scan.Token())
+		values = append(values, string(scan.ScanString()))
+	}
+	values = append(values, string(scan.ScanString()))
+	assert.Equal(t, []int{tokenCustom + 1, tokenCustom + 2, tokenCustom + 5, tokenCustom + 4}, tokens, "TestNextTokenWithWordBoundary failed")
+	assert.Equal(t, []string{"index", "inner", "1and 中in", "in_a notin", ""}, values, "TestNextTokenWithWordBoundary failed")
+}
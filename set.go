@@ -0,0 +1,80 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Set a set of strings, the array literals are compiled into the sets if the
+// parser is created with WithArraySet
+type Set struct {
+	values []string
+	index  map[string]struct{}
+}
+
+// NewSet returns a set of the values
+func NewSet(values ...string) *Set {
+	s := &Set{
+		values: values,
+		index:  make(map[string]struct{}, len(values)),
+	}
+	for _, v := range values {
+		s.index[v] = struct{}{}
+	}
+
+	return s
+}
+
+// Contains returns true if the value is in the set
+func (s *Set) Contains(value string) bool {
+	_, ok := s.index[value]
+	return ok
+}
+
+// Len returns the number of the distinct values
+func (s *Set) Len() int {
+	return len(s.index)
+}
+
+// Values returns the values in the order of the literal
+func (s *Set) Values() []string {
+	return s.values
+}
+
+func (s *Set) String() string {
+	return "[" + strings.Join(s.values, ",") + "]"
+}
+
+// In the calc func returns true if the str or num left value is in the array
+// right value, the right value is a *Set or a []string
+func In(left interface{}, right Expr, ctx interface{}) (interface{}, error) {
+	var a string
+	switch v := left.(type) {
+	case string:
+		a = v
+	case int64:
+		a = strconv.FormatInt(v, 10)
+	default:
+		return nil, fmt.Errorf("%+v is not string", left)
+	}
+
+	value, err := right.Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch values := value.(type) {
+	case *Set:
+		return values.Contains(a), nil
+	case []string:
+		for _, v := range values {
+			if v == a {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return nil, fmt.Errorf("%+v is not array", value)
+	}
+}
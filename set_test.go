@@ -0,0 +1,81 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSet(t *testing.T) {
+	s := NewSet("a", "b", "a")
+	assert.True(t, s.Contains("a"), "TestSet failed")
+	assert.False(t, s.Contains("c"), "TestSet failed")
+	assert.Equal(t, 2, s.Len(), "TestSet failed")
+	assert.Equal(t, []string{"a", "b", "a"}, s.Values(), "TestSet failed")
+	assert.Equal(t, "[a,b,a]", s.String(), "TestSet failed")
+}
+
+func TestParserWithArraySet(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("in", In),
+		WithOp("&&", testAndLogic),
+		WithVarType("num:", Num),
+		WithVarType("str:", Str),
+		WithArraySet())
+
+	ctx := make(map[string]string)
+	ctx["a"] = "b"
+	ctx["b"] = "2"
+
+	expr, err := p.Parse([]byte("({str:a} in [a,b,c]) && ({num:b} in [1,2])"), nil)
+	assert.NoError(t, err, "TestParserWithArraySet failed")
+	value, err := expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithArraySet failed")
+	assert.Equal(t, true, value, "TestParserWithArraySet failed")
+
+	var sets int
+	walk(expr, func(e Expr) {
+		if v, err := e.Exec(ctx); err == nil {
+			if _, ok := v.(*Set); ok {
+				sets++
+			}
+		}
+	})
+	assert.Equal(t, 2, sets, "TestParserWithArraySet failed")
+
+	prog, err := Compile(expr)
+	assert.NoError(t, err, "TestParserWithArraySet failed")
+	value, err = prog.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithArraySet failed")
+	assert.Equal(t, true, value, "TestParserWithArraySet failed")
+
+	data, err := MarshalExpr(expr)
+	assert.NoError(t, err, "TestParserWithArraySet failed")
+	expr, err = UnmarshalExpr(p, data)
+	assert.NoError(t, err, "TestParserWithArraySet failed")
+	value, err = expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithArraySet failed")
+	assert.Equal(t, true, value, "TestParserWithArraySet failed")
+
+	expr, err = p.Parse([]byte("{str:a} in [x,y]"), nil)
+	assert.NoError(t, err, "TestParserWithArraySet failed")
+	value, err = expr.Exec(ctx)
+	assert.NoError(t, err, "TestParserWithArraySet failed")
+	assert.Equal(t, false, value, "TestParserWithArraySet failed")
+}
+
+func TestIn(t *testing.T) {
+	value, err := In("b", &constArray{values: []string{"a", "b"}}, nil)
+	assert.NoError(t, err, "TestIn failed")
+	assert.Equal(t, true, value, "TestIn failed")
+
+	value, err = In(int64(3), newValueExpr(NewSet("1", "2")), nil)
+	assert.NoError(t, err, "TestIn failed")
+	assert.Equal(t, false, value, "TestIn failed")
+
+	_, err = In(true, newValueExpr(NewSet("1")), nil)
+	assert.Error(t, err, "TestIn failed")
+
+	_, err = In("a", newValueExpr("a"), nil)
+	assert.Error(t, err, "TestIn failed")
+}
@@ -0,0 +1,227 @@
+package expr
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/fagongzi/util/format"
+)
+
+// TokenKind the kind of the tokens returned by Tokenize
+type TokenKind int
+
+const (
+	// KindLeftParen (
+	KindLeftParen TokenKind = iota
+	// KindRightParen )
+	KindRightParen
+	// KindVarStart {
+	KindVarStart
+	// KindVarEnd }
+	KindVarEnd
+	// KindVarType the var type symbol in the var, e.g. `num:`
+	KindVarType
+	// KindVarName the var name
+	KindVarName
+	// KindOp the op symbol
+	KindOp
+	// KindString the string literal with the quotations
+	KindString
+	// KindRegexp the regexp literal with the delimiters
+	KindRegexp
+	// KindArray the array literal with the brackets
+	KindArray
+	// KindNumber the num constant
+	KindNumber
+	// KindText the other constant text
+	KindText
+	// KindComment the comment
+	KindComment
+	// KindGlob the glob literal with the delimiters
+	KindGlob
+	// KindCast the cast symbol opens a group, e.g. `num(`
+	KindCast
+	// KindMacro the macro reference, e.g. `@adult`
+	KindMacro
+)
+
+var (
+	tokenKindNames = map[TokenKind]string{
+		KindLeftParen:  "left-paren",
+		KindRightParen: "right-paren",
+		KindVarStart:   "var-start",
+		KindVarEnd:     "var-end",
+		KindVarType:    "var-type",
+		KindVarName:    "var-name",
+		KindOp:         "op",
+		KindString:     "string",
+		KindRegexp:     "regexp",
+		KindArray:      "array",
+		KindGlob:       "glob",
+		KindCast:       "cast",
+		KindMacro:      "macro",
+		KindNumber:     "number",
+		KindText:       "text",
+		KindComment:    "comment",
+	}
+)
+
+func (k TokenKind) String() string {
+	if name, ok := tokenKindNames[k]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("%d", int(k))
+}
+
+// Token a token returned by Tokenize
+type Token struct {
+	Kind TokenKind `json:"kind"`
+	// Symbol the symbol of the op, the var type and the punctuations
+	Symbol string `json:"symbol,omitempty"`
+	// Text the source text of the token
+	Text string `json:"text"`
+	// Span the span of the token in the input
+	Span Span `json:"span"`
+}
+
+// Tokenize returns all the tokens of the input scanned with the symbols registered
+// in the parser, e.g. for syntax highlighting. It's tolerant of the syntax errors,
+// the unterminated literal takes the rest of the input.
+func Tokenize(parser Parser, input []byte) ([]Token, error) {
+	p, err := templateOf(parser)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &tokenizer{
+		template: p,
+		input:    input,
+		lexer:    p.newLexer(input),
+	}
+
+	prev := 0
+	for {
+		t.lexer.NextToken()
+		token := t.lexer.Token()
+		if token == TokenEOI {
+			t.addText(prev, len(t.input))
+			return t.tokens, nil
+		}
+
+		symbol := t.lexer.TokenSymbol(token)
+		end := t.lexer.TokenIndex() + 1
+		start := end - len(symbol)
+		t.addText(prev, start)
+
+		switch token {
+		case tokenLiteral, tokenSingleLiteral, tokenRawLiteral:
+			end = t.skipTo(token)
+			t.add(KindString, symbol, start, end)
+		case tokenRegexp:
+			end = t.skipTo(tokenRegexp)
+			for end < len(t.input) && isFlag(t.input[end]) {
+				end++
+			}
+			t.add(KindRegexp, symbol, start, end)
+		case tokenGlob:
+			end = t.skipTo(tokenGlob)
+			t.add(KindGlob, symbol, start, end)
+		case tokenArrayStart:
+			end = t.skipTo(tokenArrayEnd)
+			t.add(KindArray, symbol, start, end)
+		case tokenLeftParen:
+			t.add(KindLeftParen, symbol, start, end)
+		case tokenRightParen:
+			t.add(KindRightParen, symbol, start, end)
+		case tokenVarStart:
+			t.inVar = true
+			t.add(KindVarStart, symbol, start, end)
+		case tokenVarEnd:
+			t.inVar = false
+			t.add(KindVarEnd, symbol, start, end)
+		default:
+			if _, ok := p.castTypes[token]; ok {
+				t.add(KindCast, symbol, start, end)
+			} else if _, ok := p.macroNames[token]; ok {
+				t.add(KindMacro, symbol, start, end)
+			} else if _, ok := p.varTypes[token]; ok && t.inVar {
+				t.add(KindVarType, symbol, start, end)
+			} else {
+				t.add(KindOp, symbol, start, end)
+			}
+		}
+		prev = end
+	}
+}
+
+type tokenizer struct {
+	template *parserTemplate
+	input    []byte
+	lexer    Lexer
+	tokens   []Token
+	inVar    bool
+	comments int
+}
+
+// skipTo skips the tokens until the token, returns the end offset
+func (t *tokenizer) skipTo(token int) int {
+	for {
+		t.lexer.NextToken()
+		if t.lexer.Token() == TokenEOI {
+			return len(t.input)
+		} else if t.lexer.Token() == token {
+			return t.lexer.TokenIndex() + 1
+		}
+	}
+}
+
+// addText adds the comments and the constant text between the tokens
+func (t *tokenizer) addText(start, end int) {
+	var comments []Comment
+	if c, ok := t.lexer.(CommentLexer); ok {
+		comments = c.Comments()
+	}
+	for ; t.comments < len(comments) && comments[t.comments].Span.Start < end; t.comments++ {
+		c := comments[t.comments]
+		t.addConst(start, c.Span.Start)
+		t.add(KindComment, "", c.Span.Start, c.Span.End)
+		start = c.Span.End
+	}
+
+	t.addConst(start, end)
+}
+
+func (t *tokenizer) addConst(start, end int) {
+	if start >= end {
+		return
+	}
+
+	value := t.input[start:end]
+	trimmed := bytes.TrimLeftFunc(value, isWhitespace)
+	start += len(value) - len(trimmed)
+	trimmed = bytes.TrimRightFunc(trimmed, isWhitespace)
+	if len(trimmed) == 0 {
+		return
+	}
+	end = start + len(trimmed)
+
+	kind := KindText
+	if t.inVar {
+		kind = KindVarName
+	} else if _, _, ok, _ := t.template.bareVar(trimmed); ok && t.template.opts.bareVars {
+		kind = KindVarName
+	} else if _, err := format.ParseStrInt64(string(trimmed)); err == nil {
+		kind = KindNumber
+	}
+	t.add(kind, "", start, end)
+}
+
+func (t *tokenizer) add(kind TokenKind, symbol string, start, end int) {
+	t.tokens = append(t.tokens, Token{
+		Kind:   kind,
+		Symbol: symbol,
+		Text:   string(t.input[start:end]),
+		Span:   Span{Start: start, End: end},
+	})
+}
@@ -0,0 +1,51 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("+", testAdd),
+		WithOp("==", testEqual),
+		WithOp("&&", testAndLogic),
+		WithOp("~", testMatch),
+		WithOp("in", testIn),
+		WithVarType("num:", Num),
+		WithVarType("str:", Str),
+		WithComments())
+
+	input := `({num:年龄} + 1 == 2) /* c */ && {str:a} ~ |^\|b$|i && "x\"y" in [1,2] && 中文`
+	tokens, err := Tokenize(p, []byte(input))
+	assert.NoError(t, err, "TestTokenize failed")
+
+	var kinds []TokenKind
+	var texts []string
+	for _, token := range tokens {
+		kinds = append(kinds, token.Kind)
+		texts = append(texts, token.Text)
+		assert.Equal(t, token.Text, input[token.Span.Start:token.Span.End], "TestTokenize failed")
+	}
+	assert.Equal(t, []TokenKind{KindLeftParen, KindVarStart, KindVarType, KindVarName, KindVarEnd,
+		KindOp, KindNumber, KindOp, KindNumber, KindRightParen, KindComment, KindOp,
+		KindVarStart, KindVarType, KindVarName, KindVarEnd, KindOp, KindRegexp, KindOp,
+		KindString, KindOp, KindArray, KindOp, KindText}, kinds, "TestTokenize failed")
+	assert.Equal(t, []string{"(", "{", "num:", "年龄", "}", "+", "1", "==", "2", ")", "/* c */", "&&",
+		"{", "str:", "a", "}", "~", `|^\|b$|i`, "&&", `"x\"y"`, "in", "[1,2]", "&&", "中文"}, texts, "TestTokenize failed")
+	assert.Equal(t, "+", tokens[5].Symbol, "TestTokenize failed")
+	assert.Equal(t, "var-type", tokens[2].Kind.String(), "TestTokenize failed")
+}
+
+func TestTokenizeWithUnterminated(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("==", testEqual),
+		WithVarType("str:", Str))
+
+	tokens, err := Tokenize(p, []byte(`{str:a} == "abc`))
+	assert.NoError(t, err, "TestTokenizeWithUnterminated failed")
+	assert.Equal(t, 6, len(tokens), "TestTokenizeWithUnterminated failed")
+	assert.Equal(t, KindString, tokens[5].Kind, "TestTokenizeWithUnterminated failed")
+	assert.Equal(t, `"abc`, tokens[5].Text, "TestTokenizeWithUnterminated failed")
+}
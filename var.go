@@ -2,7 +2,9 @@ package expr
 
 import (
 	"fmt"
-	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/fagongzi/util/format"
 	"github.com/fagongzi/util/hack"
@@ -18,8 +20,49 @@ var (
 	Num = VarType(1)
 	// Regexp regexp type
 	Regexp = VarType(2)
+	// Bool bool var type
+	Bool = VarType(3)
+	// Array array var type, the value is []string
+	Array = VarType(4)
+	// Glob glob var type, the value is *GlobPattern
+	Glob = VarType(5)
+	// Any any type, used by Signature to match all var types
+	Any = VarType(-1)
+
+	varTypeNames = map[VarType]string{
+		Str:    "str",
+		Num:    "num",
+		Regexp: "regexp",
+		Bool:   "bool",
+		Array:  "array",
+		Glob:   "glob",
+		Any:    "any",
+	}
 )
 
+// ParseVarType returns the var type by the name returned by VarType.String
+func ParseVarType(name string) (VarType, error) {
+	for t, n := range varTypeNames {
+		if n == name {
+			return t, nil
+		}
+	}
+
+	if v, err := strconv.Atoi(name); err == nil {
+		return VarType(v), nil
+	}
+
+	return Any, fmt.Errorf("var type %s not support", name)
+}
+
+func (t VarType) String() string {
+	if name, ok := varTypeNames[t]; ok {
+		return name
+	}
+
+	return strconv.Itoa(int(t))
+}
+
 // ValueByType returns the value by type
 func ValueByType(value []byte, varType VarType) (interface{}, error) {
 	switch varType {
@@ -40,8 +83,76 @@ func ValueByType(value []byte, varType VarType) (interface{}, error) {
 			return defaultValue(Str), nil
 		}
 
-		return regexp.Compile(hack.SliceToString(value))
+		return regexps.compile(string(value))
+	case Bool:
+		if len(value) == 0 {
+			return defaultValue(Bool), nil
+		}
+
+		return strconv.ParseBool(hack.SliceToString(value))
+	case Array:
+		if len(value) == 0 {
+			return defaultValue(Array), nil
+		}
+
+		return strings.Split(hack.SliceToString(value), ","), nil
+	case Glob:
+		if len(value) == 0 {
+			return defaultValue(Glob), nil
+		}
+
+		return CompileGlob(string(value))
 	default:
 		return nil, fmt.Errorf("%d var type not support", varType)
 	}
 }
+
+// VarRef a var referenced by a expr
+type VarRef struct {
+	Name string  `json:"name"`
+	Type VarType `json:"type"`
+	Span Span    `json:"span"`
+	// Macro the name of the macro used in the input if the var is in the macro,
+	// the Span is the span of the `@name` in the input
+	Macro string `json:"macro,omitempty"`
+}
+
+// Variables returns all the vars referenced by the expr in the order they appear in the input
+func Variables(expr Expr) []VarRef {
+	var refs []VarRef
+	collectVars(expr, nil, &refs)
+	// the operands of the commutative ops may be reordered
+	sort.SliceStable(refs, func(i, j int) bool {
+		return refs[i].Span.Start < refs[j].Span.Start
+	})
+	return refs
+}
+
+// collectVars appends the vars of the expr to the refs, the use is the macro used in the
+// input which contains the expr
+func collectVars(expr Expr, use *macroExpr, refs *[]VarRef) {
+	switch e := expr.(type) {
+	case *varExpr:
+		ref := VarRef{
+			Name: e.name,
+			Type: e.varType,
+			Span: e.span,
+		}
+		if use != nil {
+			ref.Span = use.span
+			ref.Macro = use.name
+		}
+		*refs = append(*refs, ref)
+	case *node:
+		for _, sub := range e.exprs {
+			collectVars(sub, use, refs)
+		}
+	case *castExpr:
+		collectVars(e.expr, use, refs)
+	case *macroExpr:
+		if use == nil {
+			use = e
+		}
+		collectVars(e.expr, use, refs)
+	}
+}
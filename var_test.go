@@ -0,0 +1,31 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVariables(t *testing.T) {
+	p := NewParser(testVarFactory,
+		WithOp("+", testAdd),
+		WithOp("==", testEqual),
+		WithOp("===", testStrEqual),
+		WithOp("&&", testAndLogic),
+		WithVarType("num:", Num),
+		WithVarType("str:", Str),
+		WithConst("MAX", int64(3)))
+
+	input := `({str:name}==="\"a")&&({num:age}+{MAX}==3)`
+	expr, err := p.Parse([]byte(input), nil)
+	assert.NoError(t, err, "TestVariables failed")
+
+	refs := Variables(expr)
+	assert.Equal(t, 2, len(refs), "TestVariables failed")
+	assert.Equal(t, "name", refs[0].Name, "TestVariables failed")
+	assert.Equal(t, Str, refs[0].Type, "TestVariables failed")
+	assert.Equal(t, "{str:name}", input[refs[0].Span.Start:refs[0].Span.End], "TestVariables failed")
+	assert.Equal(t, "age", refs[1].Name, "TestVariables failed")
+	assert.Equal(t, Num, refs[1].Type, "TestVariables failed")
+	assert.Equal(t, "{num:age}", input[refs[1].Span.Start:refs[1].Span.End], "TestVariables failed")
+}
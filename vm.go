@@ -0,0 +1,279 @@
+package expr
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+var (
+	vmPool = sync.Pool{
+		New: func() interface{} {
+			return NewVM()
+		},
+	}
+)
+
+// value the unboxed value on the VM stack, v holds the boxed value if the value
+// is not a int64, string or bool, or it was boxed already.
+type value struct {
+	kind VarType
+	i    int64
+	s    string
+	b    bool
+	v    interface{}
+}
+
+func toValue(v interface{}) value {
+	switch x := v.(type) {
+	case int64:
+		return value{kind: Num, i: x, v: v}
+	case string:
+		return value{kind: Str, s: x, v: v}
+	case bool:
+		return value{kind: Bool, b: x, v: v}
+	default:
+		return value{kind: typeOfValue(v), v: v}
+	}
+}
+
+func (v value) boxed() interface{} {
+	if v.v != nil {
+		return v.v
+	}
+
+	switch v.kind {
+	case Num:
+		return v.i
+	case Str:
+		return v.s
+	case Bool:
+		return v.b
+	default:
+		return nil
+	}
+}
+
+// VM a stack based virtual machine to execute the programs, a VM can be reused
+// but not safe for concurrent use.
+type VM struct {
+	stack []value
+}
+
+// NewVM returns a VM
+func NewVM() *VM {
+	return &VM{}
+}
+
+// Run executes the program and returns the result
+func (vm *VM) Run(prog *Program, ctx interface{}) (interface{}, error) {
+	result, err := vm.run(prog, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.boxed(), nil
+}
+
+// RunContext executes the program with the context, the context is checked before
+// executing the vars and the ops called with the right expr
+func (vm *VM) RunContext(ctx context.Context, prog *Program, env interface{}) (interface{}, error) {
+	result, err := vm.runContext(ctx, prog, env, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.boxed(), nil
+}
+
+// RunBool executes the program and returns the bool result
+func (vm *VM) RunBool(prog *Program, ctx interface{}) (bool, error) {
+	result, err := vm.run(prog, ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if result.kind != Bool {
+		return false, fmt.Errorf("%+v is not bool", result.boxed())
+	}
+
+	return result.b, nil
+}
+
+// RunInt64 executes the program and returns the int64 result
+func (vm *VM) RunInt64(prog *Program, ctx interface{}) (int64, error) {
+	result, err := vm.run(prog, ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if result.kind != Num {
+		return 0, fmt.Errorf("%+v is not int64", result.boxed())
+	}
+
+	return result.i, nil
+}
+
+// RunString executes the program and returns the string result
+func (vm *VM) RunString(prog *Program, ctx interface{}) (string, error) {
+	result, err := vm.run(prog, ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if result.kind != Str {
+		return "", fmt.Errorf("%+v is not string", result.boxed())
+	}
+
+	return result.s, nil
+}
+
+func (vm *VM) run(prog *Program, env interface{}) (value, error) {
+	if prog.budget != nil {
+		return vm.runContext(withTracker(context.Background(), prog.budget), prog, env, false)
+	}
+
+	return vm.runContext(context.Background(), prog, env, true)
+}
+
+// runContext executes the program, the vars and the ops are executed without the ctx
+// if plain is true
+func (vm *VM) runContext(ctx context.Context, prog *Program, env interface{}, plain bool) (value, error) {
+	if len(vm.stack) < prog.maxStack {
+		vm.stack = make([]value, prog.maxStack)
+	}
+
+	stack := vm.stack
+	defer vm.reset(prog.maxStack)
+
+	t := trackerFrom(ctx)
+	if t != nil {
+		if err := t.enter(prog.depth); err != nil {
+			return value{}, err
+		}
+		defer t.leave(prog.depth)
+	}
+
+	sp := 0
+	for pc := 0; pc < len(prog.code); pc++ {
+		ins := prog.code[pc]
+		switch ins.code {
+		case opConst:
+			stack[sp] = prog.consts[ins.arg]
+			sp++
+		case opVar:
+			v, err := execVar(ctx, plain, prog.vars[ins.arg], env)
+			if err != nil {
+				return value{}, err
+			}
+			stack[sp] = toValue(v)
+			sp++
+		case opInt64:
+			if err := t.addOp(); err != nil {
+				return value{}, err
+			}
+			a, b := &stack[sp-2], &stack[sp-1]
+			if err := expectKind(a, b, Num, "int64"); err != nil {
+				return value{}, err
+			}
+			*a = value{kind: Num, i: prog.int64Fns[ins.arg](a.i, b.i)}
+			sp--
+		case opInt64Cmp:
+			if err := t.addOp(); err != nil {
+				return value{}, err
+			}
+			a, b := &stack[sp-2], &stack[sp-1]
+			if err := expectKind(a, b, Num, "int64"); err != nil {
+				return value{}, err
+			}
+			*a = value{kind: Bool, b: prog.int64Cmps[ins.arg](a.i, b.i)}
+			sp--
+		case opStrCmp:
+			if err := t.addOp(); err != nil {
+				return value{}, err
+			}
+			a, b := &stack[sp-2], &stack[sp-1]
+			if err := expectKind(a, b, Str, "string"); err != nil {
+				return value{}, err
+			}
+			*a = value{kind: Bool, b: prog.strCmps[ins.arg](a.s, b.s)}
+			sp--
+		case opJumpIfFalse, opJumpIfTrue:
+			if err := t.addOp(); err != nil {
+				return value{}, err
+			}
+			top := &stack[sp-1]
+			if top.kind != Bool {
+				return value{}, fmt.Errorf("%+v is not bool", top.boxed())
+			}
+			if top.b == (ins.code == opJumpIfTrue) {
+				pc = ins.arg - 1
+			}
+		case opAssertBool:
+			if stack[sp-1].kind != Bool {
+				return value{}, fmt.Errorf("%+v is not bool", stack[sp-1].boxed())
+			}
+		case opPop:
+			sp--
+		case opCast:
+			v, err := castValue(stack[sp-1].boxed(), VarType(ins.arg))
+			if err != nil {
+				return value{}, err
+			}
+			stack[sp-1] = toValue(v)
+		case opCall:
+			if err := t.addOp(); err != nil {
+				return value{}, err
+			}
+			v, err := prog.calls[ins.arg].exec(ctx, plain, stack[sp-1].boxed(), env)
+			if err != nil {
+				return value{}, err
+			}
+			stack[sp-1] = toValue(v)
+		}
+	}
+
+	return stack[0], nil
+}
+
+func execVar(ctx context.Context, plain bool, expr Expr, env interface{}) (interface{}, error) {
+	if plain {
+		return expr.Exec(env)
+	}
+
+	return ExecContext(ctx, expr, env)
+}
+
+func (c *call) exec(ctx context.Context, plain bool, left interface{}, env interface{}) (interface{}, error) {
+	if plain {
+		return c.fn(left, c.right, env)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if c.ctxFn != nil {
+		return c.ctxFn(ctx, left, bindContext(ctx, c.right), env)
+	}
+
+	return c.fn(left, bindContext(ctx, c.right), env)
+}
+
+func (vm *VM) reset(n int) {
+	for i := 0; i < n; i++ {
+		vm.stack[i] = value{}
+	}
+}
+
+func expectKind(a, b *value, kind VarType, name string) error {
+	if a.kind != kind {
+		return fmt.Errorf("%+v is not %s", a.boxed(), name)
+	}
+
+	if b.kind != kind {
+		return fmt.Errorf("%+v is not %s", b.boxed(), name)
+	}
+
+	return nil
+}
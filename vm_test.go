@@ -0,0 +1,150 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testBoxedVarExpr struct {
+	attr string
+}
+
+func (expr *testBoxedVarExpr) Exec(ctx interface{}) (interface{}, error) {
+	return ctx.(map[string]interface{})[expr.attr], nil
+}
+
+func testBoxedVarFactory(value []byte, valueType VarType) (Expr, error) {
+	return &testBoxedVarExpr{
+		attr: string(value),
+	}, nil
+}
+
+func newTestVMParser(opts ...Option) Parser {
+	opts = append(opts,
+		WithInt64Op("+", func(a, b int64) int64 { return a + b }),
+		WithInt64CmpOp("==", func(a, b int64) bool { return a == b }),
+		WithStrCmpOp("==", func(a, b string) bool { return a == b }),
+		WithAndOp("&&"),
+		WithOrOp("||"),
+		WithVarType("num:", Num),
+		WithVarType("str:", Str))
+	return NewParser(testBoxedVarFactory, opts...)
+}
+
+func TestCompile(t *testing.T) {
+	p := newTestVMParser(WithOp("in", testStrIn))
+
+	ctx := make(map[string]interface{})
+	ctx["a"] = int64(1000)
+	ctx["b"] = "abc"
+
+	cases := []struct {
+		input  string
+		result interface{}
+	}{
+		{"{num:a}+1", int64(1001)},
+		{"({num:a}+1==1001)&&({str:b}==abc)", true},
+		{"({num:a}==1)&&({str:b}==abc)", false},
+		{"({num:a}==1)||({str:b}==abc)", true},
+		{"({num:a}==1)||({str:b}==abcd)", false},
+		{"{str:b} in [a,abc]", true},
+		{"({str:b} in [a,b])||({num:a}+1000==2000)", true},
+	}
+
+	vm := NewVM()
+	for _, c := range cases {
+		expr, err := p.Parse([]byte(c.input), nil)
+		assert.NoError(t, err, "TestCompile failed")
+
+		expect, err := expr.Exec(ctx)
+		assert.NoError(t, err, "TestCompile failed")
+		assert.Equal(t, c.result, expect, "TestCompile failed")
+
+		prog, err := Compile(expr)
+		assert.NoError(t, err, "TestCompile failed")
+		value, err := vm.Run(prog, ctx)
+		assert.NoError(t, err, "TestCompile failed")
+		assert.Equal(t, c.result, value, "TestCompile failed: %s", c.input)
+
+		value, err = prog.Exec(ctx)
+		assert.NoError(t, err, "TestCompile failed")
+		assert.Equal(t, c.result, value, "TestCompile failed: %s", c.input)
+	}
+}
+
+func TestCompileTypeMismatch(t *testing.T) {
+	p := newTestVMParser()
+
+	ctx := make(map[string]interface{})
+	ctx["a"] = "abc"
+
+	expr, err := p.Parse([]byte("{num:a}+1"), nil)
+	assert.NoError(t, err, "TestCompileTypeMismatch failed")
+	prog, err := Compile(expr)
+	assert.NoError(t, err, "TestCompileTypeMismatch failed")
+	_, err = NewVM().Run(prog, ctx)
+	assert.Error(t, err, "TestCompileTypeMismatch failed")
+}
+
+func TestVMZeroAllocs(t *testing.T) {
+	p := newTestVMParser()
+
+	ctx := make(map[string]interface{})
+	ctx["a"] = int64(1000)
+	ctx["b"] = "abc"
+
+	expr, err := p.Parse([]byte("(({num:a}+1==1001)&&({str:b}==abc))||({num:a}==1)"), nil)
+	assert.NoError(t, err, "TestVMZeroAllocs failed")
+	prog, err := Compile(expr)
+	assert.NoError(t, err, "TestVMZeroAllocs failed")
+
+	vm := NewVM()
+	allocs := testing.AllocsPerRun(100, func() {
+		value, err := vm.RunBool(prog, ctx)
+		if err != nil || !value {
+			t.Fatalf("TestVMZeroAllocs failed with %v, %v", value, err)
+		}
+	})
+	assert.Equal(t, float64(0), allocs, "TestVMZeroAllocs failed")
+}
+
+func benchmarkExpr(b *testing.B) (Expr, map[string]interface{}) {
+	p := newTestVMParser()
+
+	ctx := make(map[string]interface{})
+	ctx["a"] = int64(1000)
+	ctx["b"] = "abc"
+
+	expr, err := p.Parse([]byte("(({num:a}+1==1001)&&({str:b}==abc))||({num:a}==1)"), nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return expr, ctx
+}
+
+func BenchmarkNodeExec(b *testing.B) {
+	expr, ctx := benchmarkExpr(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		expr.Exec(ctx)
+	}
+}
+
+func BenchmarkVMRun(b *testing.B) {
+	expr, ctx := benchmarkExpr(b)
+	prog, err := Compile(expr)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	vm := NewVM()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vm.RunBool(prog, ctx)
+	}
+}